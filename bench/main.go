@@ -1,4 +1,4 @@
-// 压测入口：-stage a|b|c
+// 压测入口：-stage a|b|c|d|e
 package main
 
 import (
@@ -14,8 +14,8 @@ type stageOpts struct {
 }
 
 func main() {
-	stage := flag.String("stage", "", "压测阶段: a(参数寻优) | b(容量扩展) | c(高并发) | d(内存vs mmap)")
-	shards := flag.Int("shards", 1, "分片数，>1 时使用 ShardedIndex（仅 stage b/c 生效）")
+	stage := flag.String("stage", "", "压测阶段: a(参数寻优) | b(容量扩展) | c(高并发) | d(内存vs mmap) | e(构建吞吐)")
+	shards := flag.Int("shards", 1, "分片数，>1 时使用 ShardedIndex（仅 stage b/c/e 生效）")
 	offheap := flag.Bool("offheap", false, "启用 Off-heap 内存（需 CGO）")
 	batch := flag.Int("batch", 0, "批量查询大小，>1 时使用 SearchMultiPathBatch（仅 stage c 生效）")
 	flag.Parse()
@@ -30,8 +30,10 @@ func main() {
 		runStageC(stageOpts)
 	case "d":
 		runStageD(stageOpts)
+	case "e":
+		runStageE(stageOpts)
 	default:
-		log.Fatalf("请指定 -stage a|b|c|d")
+		log.Fatalf("请指定 -stage a|b|c|d|e")
 	}
 	fmt.Println("压测完成")
 }
@@ -51,6 +51,15 @@ type StageCRow struct {
 	P99P50Ratio  float64
 }
 
+// StageERow 阶段 E 单行数据
+type StageERow struct {
+	SplitThreshold int
+	VectorCount    int
+	BuildDurMs     float64
+	AddsPerSec     float64
+	AddAvgUs       float64
+}
+
 // Percentile 计算切片中第 p 百分位（0-100），输入需已排序
 func Percentile(sorted []float64, p float64) float64 {
 	if len(sorted) == 0 {
@@ -171,6 +180,29 @@ func WriteStageCCSV(rows []StageCRow, path string) error {
 	return w.Error()
 }
 
+// WriteStageECSV 写入阶段 E 报告
+func WriteStageECSV(rows []StageERow, path string) error {
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"SplitThreshold", "VectorCount", "BuildDurMs", "AddsPerSec", "AddAvgUs"})
+	for _, r := range rows {
+		w.Write([]string{
+			fmt.Sprintf("%d", r.SplitThreshold),
+			fmt.Sprintf("%d", r.VectorCount),
+			fmt.Sprintf("%.2f", r.BuildDurMs),
+			fmt.Sprintf("%.0f", r.AddsPerSec),
+			fmt.Sprintf("%.2f", r.AddAvgUs),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
 // ReportDir 报告输出目录
 const ReportDir = "report"
 
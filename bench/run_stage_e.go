@@ -0,0 +1,62 @@
+// 阶段 E: 构建吞吐，对比不同 SplitThreshold 下的 Add 速率（叶子越大，质心维护开销越显著）
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ic-timon/da-hvri/bench/gen"
+	"github.com/ic-timon/da-hvri/bench/metrics"
+	"github.com/ic-timon/da-hvri/indexer"
+)
+
+func runStageE(opts stageOpts) {
+	const vectorCount = 100_000
+	const dim = 512
+
+	thresholds := []int{256, 512, 1024, 2048}
+	vecs := gen.RandomVectors(vectorCount, dim, 2048)
+
+	var rows []metrics.StageERow
+	for _, thr := range thresholds {
+		fmt.Printf("阶段 E: SplitThreshold=%d 向量规模 %d shards=%d\n", thr, vectorCount, opts.shards)
+		cfg := indexer.DefaultConfig()
+		cfg.SplitThreshold = thr
+		cfg.UseOffheap = opts.offheap
+
+		metrics.GC()
+		var idx indexerSearcher
+		if opts.shards > 1 {
+			idx = indexer.NewShardedIndex(cfg, opts.shards)
+		} else {
+			idx = indexer.NewTree(cfg)
+		}
+
+		t0 := time.Now()
+		for i, v := range vecs {
+			if !idx.Add(v, uint64(i)) {
+				panic("add failed")
+			}
+		}
+		buildDur := time.Since(t0)
+
+		row := metrics.StageERow{
+			SplitThreshold: thr,
+			VectorCount:    vectorCount,
+			BuildDurMs:     float64(buildDur.Nanoseconds()) / 1e6,
+			AddsPerSec:     float64(vectorCount) / buildDur.Seconds(),
+			AddAvgUs:       float64(buildDur.Nanoseconds()) / 1e3 / vectorCount,
+		}
+		rows = append(rows, row)
+		fmt.Printf("  Build=%.0fms Adds/s=%.0f AddAvg=%.1fus\n", row.BuildDurMs, row.AddsPerSec, row.AddAvgUs)
+	}
+
+	path := metrics.ReportPath("bench_report_stage_e_")
+	if opts.shards > 1 {
+		path = metrics.ReportPath("bench_report_stage_e_sharded_")
+	}
+	if err := metrics.WriteStageECSV(rows, path); err != nil {
+		panic(err)
+	}
+	fmt.Printf("报告已写入 %s\n", path)
+}
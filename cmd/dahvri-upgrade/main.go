@@ -0,0 +1,50 @@
+// 索引格式升级：将旧版本索引文件重写为当前格式（保留 ID、向量与树结构）
+//
+//	dahvri-upgrade [-o dst.bin] [-split-threshold N] src.bin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ic-timon/da-hvri/indexer"
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+func main() {
+	out := flag.String("o", "", "输出文件路径，为空时原地升级（原子替换源文件）")
+	splitThreshold := flag.Int("split-threshold", 0, "构建时的分裂阈值，>0 时写入元数据（旧文件未记录构建配置）")
+	check := flag.Bool("check", false, "仅打印文件格式版本，不做升级")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [选项] src.bin\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src := flag.Arg(0)
+	h, err := store.ReadHeader(src)
+	if err != nil {
+		log.Fatalf("读取 %s 失败: %v", src, err)
+	}
+	if *check {
+		fmt.Printf("%s: 格式版本 %d（当前 %d）\n", src, h.Version, store.FormatVersion)
+		return
+	}
+	dst := *out
+	if dst == "" {
+		dst = src
+	}
+	var opts []indexer.SaveOption
+	if *splitThreshold > 0 {
+		opts = append(opts, indexer.WithMetadata(store.Metadata{indexer.MetaSplitThreshold: int64(*splitThreshold)}))
+	}
+	if err := indexer.Upgrade(src, dst, opts...); err != nil {
+		log.Fatalf("升级失败: %v", err)
+	}
+	fmt.Printf("%s: 版本 %d -> %d，已写入 %s\n", src, h.Version, store.FormatVersion, dst)
+}
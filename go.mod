@@ -4,4 +4,4 @@ go 1.21
 
 require golang.org/x/sys v0.20.0
 
-require github.com/edsrzf/mmap-go v1.2.0 // indirect
+require github.com/edsrzf/mmap-go v1.2.0
@@ -16,6 +16,7 @@ type Block interface {
 	SetVector(slot int, vec []float32)
 	GetVector(slot int, dst []float32) bool
 	DotProductBatch(query []float32, n int) []float64
+	DotProductBatchInto(query []float32, n int, dst []float64) []float64
 	Close() // releases resources; no-op for heap blocks, C.free for off-heap
 }
 
@@ -76,5 +77,10 @@ func (b *DataBlock) DotProductBatch(query []float32, n int) []float64 {
 	return simd.DotProductBatchFlat(query, b.data, n)
 }
 
+// DotProductBatchInto is DotProductBatch writing into dst; it returns dst[:n] or nil.
+func (b *DataBlock) DotProductBatchInto(query []float32, n int, dst []float64) []float64 {
+	return simd.DotProductBatchFlatInto(query, b.data, n, dst)
+}
+
 // Close is a no-op for heap blocks.
 func (b *DataBlock) Close() {}
@@ -5,9 +5,12 @@ import (
 	"github.com/ic-timon/da-hvri/simd"
 )
 
-// DataBlockMmap is a Block backed by mmap'd file region (read-only).
+// DataBlockMmap is a Block backed by mmap'd file region. It is read-only unless the
+// store is a store.WritableBlockStore, in which case SetVector writes in place.
 type DataBlockMmap struct {
 	store           store.BlockStore
+	rw              store.WritableBlockStore // nil for read-only stores
+	prefetcher      store.Prefetcher         // non-nil when the store reads blocks on demand (not resident)
 	offset          int64
 	vectorsPerBlock int
 }
@@ -17,13 +20,22 @@ func NewDataBlockMmap(s store.BlockStore, offset int64, vectorsPerBlock int) *Da
 	if vectorsPerBlock <= 0 {
 		vectorsPerBlock = 64
 	}
+	rw, _ := s.(store.WritableBlockStore)
+	pf, _ := s.(store.Prefetcher)
 	return &DataBlockMmap{
 		store:           s,
+		rw:              rw,
+		prefetcher:      pf,
 		offset:          offset,
 		vectorsPerBlock: vectorsPerBlock,
 	}
 }
 
+// Offset returns the file offset of the block.
+func (b *DataBlockMmap) Offset() int64 {
+	return b.offset
+}
+
 // VectorsPerBlock returns the number of vectors in the block.
 func (b *DataBlockMmap) VectorsPerBlock() int {
 	return b.vectorsPerBlock
@@ -36,11 +48,22 @@ func (b *DataBlockMmap) FloatsPerBlock() int {
 
 // Data returns the underlying slice for use with simd.DotProductBatchFlat.
 func (b *DataBlockMmap) Data() []float32 {
-	return b.store.BlockView(b.offset)
+	return b.store.BlockView(b.offset, b.vectorsPerBlock*BlockDim)
 }
 
-// SetVector is a no-op for mmap blocks (read-only).
-func (b *DataBlockMmap) SetVector(slot int, vec []float32) {}
+// SetVector writes the vector at slot for writable stores; no-op for read-only mappings.
+func (b *DataBlockMmap) SetVector(slot int, vec []float32) {
+	if b.rw == nil || slot < 0 || slot >= b.vectorsPerBlock || len(vec) != BlockDim {
+		return
+	}
+	d := b.Data()
+	if d == nil {
+		return
+	}
+	start := slot * BlockDim
+	copy(d[start:start+BlockDim], vec)
+	b.rw.MarkDirty(b.offset)
+}
 
 // GetVector reads the vector at slot into dst.
 func (b *DataBlockMmap) GetVector(slot int, dst []float32) bool {
@@ -65,5 +88,14 @@ func (b *DataBlockMmap) DotProductBatch(query []float32, n int) []float64 {
 	return simd.DotProductBatchFlat(query, d, n)
 }
 
+// DotProductBatchInto is DotProductBatch writing into dst; it returns dst[:n] or nil.
+func (b *DataBlockMmap) DotProductBatchInto(query []float32, n int, dst []float64) []float64 {
+	d := b.Data()
+	if d == nil {
+		return nil
+	}
+	return simd.DotProductBatchFlatInto(query, d, n, dst)
+}
+
 // Close is a no-op for mmap blocks (store owns the mapping).
 func (b *DataBlockMmap) Close() {}
@@ -77,6 +77,11 @@ func (b *DataBlockOffheap) DotProductBatch(query []float32, n int) []float64 {
 	return simd.DotProductBatchFlat(query, b.Data(), n)
 }
 
+// DotProductBatchInto is DotProductBatch writing into dst; it returns dst[:n] or nil.
+func (b *DataBlockOffheap) DotProductBatchInto(query []float32, n int, dst []float64) []float64 {
+	return simd.DotProductBatchFlatInto(query, b.Data(), n, dst)
+}
+
 // Close frees the C.malloc-allocated memory.
 func (b *DataBlockOffheap) Close() {
 	if b.ptr != nil {
@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// errStaleCheckpoint means the checkpoint state no longer describes the file or the tree.
+var errStaleCheckpoint = errors.New("checkpoint state is out of date")
+
+// checkpointState records what the last checkpoint wrote to path. Blocks are keyed by identity:
+// leaves copied by a Snapshot share their blocks, so a block keeps its file offset until a split
+// replaces it.
+type checkpointState struct {
+	path       string
+	epoch      uint64 // snapshot epoch of the last checkpoint; older nodes are unchanged since
+	end        int64  // end of the used file region
+	treeOffset uint64 // header TreeOffset written by the last checkpoint
+	offsets    map[Block]int64
+	written    map[Block]int  // vectors written per block
+	meta       store.Metadata // metadata written by the last checkpoint, carried into the next
+}
+
+// Checkpoint makes the tree durable at path by appending only what changed since the last
+// Checkpoint to that path: new blocks, the new vectors of partially filled blocks, and a new tree
+// structure section, published by rewriting the header (see store.CommitTree). Vectors are written
+// into unused slots of existing blocks, which the previous tree section never reads, so a crash
+// leaves the previous checkpoint loadable. The first Checkpoint to a path, or one after the file was
+// changed by someone else, writes a compact file like Compact.
+// Space held by blocks of split leaves and by old tree sections is reclaimed only by Compact.
+// Metadata of the previous checkpoint is carried over and merged with opts.
+// With WithEncryptionKey every Checkpoint rewrites the whole file encrypted, like SaveToAtomic.
+// Checkpoint runs off a Snapshot, so Add may continue.
+func (t *Tree) Checkpoint(path string, opts ...SaveOption) error {
+	t.ckptMu.Lock()
+	defer t.ckptMu.Unlock()
+	ck := t.ckpt
+	if ck == nil || ck.path != path || !ck.matchesFile() || applySaveOptions(opts).key != nil {
+		return t.compactLocked(path, opts)
+	}
+	snap := t.Snapshot()
+	if snap.root == nil {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = writeCheckpoint(f, ck, snap, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.ckpt = nil
+		if errors.Is(err, errStaleCheckpoint) {
+			return t.compactLocked(path, opts)
+		}
+	}
+	return err
+}
+
+// Compact rewrites path atomically with only the live blocks and tree, dropping space left by
+// earlier checkpoints. Later Checkpoints to path append to the compacted file.
+func (t *Tree) Compact(path string, opts ...SaveOption) error {
+	t.ckptMu.Lock()
+	defer t.ckptMu.Unlock()
+	return t.compactLocked(path, opts)
+}
+
+func (t *Tree) compactLocked(path string, opts []SaveOption) error {
+	var meta store.Metadata
+	if t.ckpt != nil && t.ckpt.path == path {
+		meta = t.ckpt.meta
+	}
+	t.ckpt = nil
+	snap := t.Snapshot()
+	if snap.root == nil {
+		return nil
+	}
+	if applySaveOptions(opts).key != nil {
+		// Blocks cannot be patched in place inside the encrypted container.
+		return snap.SaveToAtomic(path, opts...)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	ck := &checkpointState{
+		path:    path,
+		end:     store.PageSize,
+		offsets: make(map[Block]int64),
+		written: make(map[Block]int),
+		meta:    meta,
+	}
+	err = writeCheckpoint(f, ck, snap, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	_ = os.Remove(path) // ignore error if not exists
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	t.ckpt = ck
+	return nil
+}
+
+// matchesFile reports whether the file at ck.path still has the header the last checkpoint wrote.
+func (ck *checkpointState) matchesFile() bool {
+	st, err := os.Stat(ck.path)
+	if err != nil || st.Size() < ck.end {
+		return false
+	}
+	h, err := store.ReadHeader(ck.path)
+	return err == nil && h.TreeOffset == ck.treeOffset
+}
+
+// writeCheckpoint writes the blocks of leaves changed since ck.epoch and commits the tree of snap.
+// ck is updated in place; on error it must be discarded.
+func writeCheckpoint(f *os.File, ck *checkpointState, snap *Snapshot, opts []SaveOption) error {
+	cfg := snap.cfg.OrDefault()
+	blockSize := blockSizeBytes(cfg.VectorsPerBlock)
+	base := ck.meta
+	if base == nil {
+		base = snap.meta
+	}
+	meta := buildMetadata(base, cfg, opts)
+	metaBytes, err := store.EncodeMetadata(meta)
+	if err != nil {
+		return err
+	}
+
+	var dirty []blockRef
+	collectDirtyBlocks(snap.root, ck.epoch, &dirty)
+	end := alignUp(ck.end, pageAlign)
+	var buf bytes.Buffer
+	for _, ref := range dirty {
+		off, ok := ck.offsets[ref.block]
+		from := ck.written[ref.block]
+		if !ok {
+			off = end
+			end += blockSize
+			from = 0
+			ck.offsets[ref.block] = off
+		}
+		if ref.vectors <= from {
+			continue
+		}
+		buf.Reset()
+		if err := binary.Write(&buf, binary.LittleEndian, ref.block.Data()[from*BlockDim:ref.vectors*BlockDim]); err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(buf.Bytes(), off+int64(from)*BlockDim*4); err != nil {
+			return err
+		}
+		ck.written[ref.block] = ref.vectors
+	}
+
+	var treeBuf bytes.Buffer
+	var blocks []blockRef
+	if err := serializeNode(&treeBuf, snap.root, &blocks); err != nil {
+		return err
+	}
+	routing := make([]int64, len(blocks))
+	offsets := make(map[Block]int64, len(blocks))
+	written := make(map[Block]int, len(blocks))
+	for i, ref := range blocks {
+		off, ok := ck.offsets[ref.block]
+		if !ok || ck.written[ref.block] != ref.vectors {
+			return errStaleCheckpoint
+		}
+		routing[i] = off
+		offsets[ref.block] = off
+		written[ref.block] = ref.vectors
+	}
+	h := &store.Header{
+		Dim:             BlockDim,
+		VectorsPerBlock: uint32(cfg.VectorsPerBlock),
+		BlockSizeBytes:  uint32(blockSize),
+	}
+	newEnd, err := store.CommitTree(f, end, h, treeBuf.Bytes(), routing, metaBytes)
+	if err != nil {
+		return err
+	}
+	// Forget blocks of split leaves; their space is reclaimed by Compact.
+	ck.offsets, ck.written = offsets, written
+	ck.end = newEnd
+	ck.epoch = snap.epoch
+	ck.treeOffset = h.TreeOffset
+	ck.meta = meta
+	return nil
+}
+
+// collectDirtyBlocks appends the blocks of leaves under n changed at or after epoch. Subtrees
+// whose root is older are unchanged, since Add copies every node on the path it writes.
+func collectDirtyBlocks(n Node, epoch uint64, out *[]blockRef) {
+	switch n := n.(type) {
+	case *LeafNode:
+		if n.epoch < epoch {
+			return
+		}
+		appendLeafBlocks(n, out)
+	case *InternalNode:
+		if n.epoch < epoch {
+			return
+		}
+		for i := range n.children {
+			if child := n.Child(i); child != nil {
+				collectDirtyBlocks(child, epoch, out)
+			}
+		}
+	}
+}
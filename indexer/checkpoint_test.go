@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersist_Checkpoint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(700, 31)
+	path := filepath.Join(t.TempDir(), "ckpt.bin")
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+
+	load := func(n int) {
+		t.Helper()
+		loaded, err := NewTreeFromFile(path, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer loaded.ClosePersisted()
+		checkVectors(t, loaded, vecs[:n])
+	}
+	fileSize := func() int64 {
+		t.Helper()
+		st, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.Size()
+	}
+
+	for i, v := range vecs[:600] {
+		tree.Add(v, uint64(i))
+	}
+	if err := tree.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	load(600)
+	full := fileSize()
+
+	// A few adds append a few blocks and a tree section, not a second copy of the data.
+	for i, v := range vecs[600:610] {
+		tree.Add(v, uint64(600+i))
+	}
+	if err := tree.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	load(610)
+	if grown := fileSize() - full; grown >= full/2 {
+		t.Errorf("checkpoint grew file by %d bytes (full file %d)", grown, full)
+	}
+
+	for i, v := range vecs[610:] {
+		tree.Add(v, uint64(610+i))
+	}
+	if err := tree.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	load(700)
+	before := fileSize()
+	if err := tree.Compact(path); err != nil {
+		t.Fatal(err)
+	}
+	load(700)
+	if after := fileSize(); after > before {
+		t.Errorf("compact grew file: %d -> %d", before, after)
+	}
+
+	// A file replaced behind the tree's back is rewritten rather than appended to.
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	extra := randomVectors(1, 32)[0]
+	tree.Add(extra, 700)
+	if err := tree.Checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewTreeFromFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.ClosePersisted()
+	if got := len(collectVectors(*loaded.Root().Load())); got != 701 {
+		t.Errorf("vectors after checkpoint over replaced file: got %d want 701", got)
+	}
+}
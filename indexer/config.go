@@ -1,14 +1,52 @@
 package indexer
 
+// LoadMode selects where the blocks of a persisted index live after LoadFrom.
+type LoadMode int
+
+const (
+	// LoadMmap keeps blocks in the read-only file mapping (default). The loaded tree is read-only.
+	LoadMmap LoadMode = iota
+	// LoadHeap copies blocks into heap memory and closes the file. The loaded tree accepts Add.
+	LoadHeap
+	// LoadOffheap copies blocks into C.malloc memory (requires CGO, falls back to heap). The loaded tree accepts Add.
+	LoadOffheap
+	// LoadPread keeps only the tree structure (centroids, IDs) in memory and reads blocks with pread
+	// into a bounded cache of BlockCacheBlocks blocks, for indexes larger than RAM. The loaded tree is read-only.
+	LoadPread
+)
+
+// ConfigMergePolicy selects how the build config recorded in an index file combines with the
+// Config passed to the loader. VectorsPerBlock always comes from the file, since it is the block
+// layout on disk.
+type ConfigMergePolicy int
+
+const (
+	// ConfigFromFile uses the file's SplitThreshold, SearchWidth and PruneEpsilon (default), so a
+	// file is served with the knobs it was built and tuned with. Files without a recorded config
+	// keep the caller's values.
+	ConfigFromFile ConfigMergePolicy = iota
+	// ConfigFromCaller keeps the caller's values, e.g. to experiment with search knobs.
+	ConfigFromCaller
+)
+
 // Config holds index parameters.
 type Config struct {
-	VectorsPerBlock   int     // vectors per block, default 64
-	SplitThreshold    int     // leaf split threshold, default 512
-	SearchWidth       int     // multi-path search width, default 3
-	PruneEpsilon      float64 // prune branches with score < maxScore - epsilon, default 0.1
-	UseOffheap        bool    // use C.malloc for blocks (requires CGO), reduces GC pressure
-	PersistPath       string  // non-empty and file exists: NewTree auto LoadFrom (mmap); read-only tree
-	SearchPoolWorkers int     // when >0, enables single-tree search pool (recommend NumCPU) for mmap throttling
+	VectorsPerBlock   int               // vectors per block, default 64
+	SplitThreshold    int               // leaf split threshold, default 512
+	SearchWidth       int               // multi-path search width, default 3
+	PruneEpsilon      float64           // prune branches with score < maxScore - epsilon, default 0.1
+	UseOffheap        bool              // use C.malloc for blocks (requires CGO), reduces GC pressure
+	PersistPath       string            // non-empty and file exists: NewTree auto LoadFrom (panics if it fails); read-only unless LoadMode is heap/off-heap
+	LoadMode          LoadMode          // LoadFrom target: LoadMmap (default, read-only), LoadHeap or LoadOffheap (writable), LoadPread (read-only, cached)
+	BlockCacheBlocks  int               // block cache size for LoadPread and LoadFromReaderAt, default store.DefaultCacheBlocks
+	ConfigMerge       ConfigMergePolicy // on load: ConfigFromFile (default) or ConfigFromCaller for the persisted knobs
+	SearchPoolWorkers int               // when >0, enables single-tree search pool (recommend NumCPU) for mmap throttling
+	PrefetchDistance  int               // leaf scans prefetch the head of the block this many ahead, default 2; <0 disables
+	RoutingDrift      float64           // refresh an internal routing centroid once its subtree mean moves this far (L2), default 0.01; <0 keeps split-time centroids
+
+	Model           ModelFingerprint    // embedding model of the vectors; recorded on save and checked on load
+	ModelMismatch   ModelMismatchPolicy // on load: ModelMismatchReject (default) or ModelMismatchWarn
+	OnModelMismatch func(err error)     // ModelMismatchWarn hook; nil logs with the standard logger
 }
 
 // DefaultConfig returns the default configuration.
@@ -40,3 +78,29 @@ func (c *Config) OrDefault() *Config {
 	}
 	return c
 }
+
+// defaultPrefetchDistance is the leaf-scan prefetch distance used when Config.PrefetchDistance is 0.
+const defaultPrefetchDistance = 2
+
+// prefetchBlocks returns the leaf-scan prefetch distance in blocks; 0 disables prefetch.
+func (c *Config) prefetchBlocks() int {
+	switch {
+	case c.PrefetchDistance < 0:
+		return 0
+	case c.PrefetchDistance == 0:
+		return defaultPrefetchDistance
+	}
+	return c.PrefetchDistance
+}
+
+// defaultRoutingDrift is the routing-centroid refresh threshold used when Config.RoutingDrift is 0.
+const defaultRoutingDrift = 0.01
+
+// routingDrift returns the L2 distance a subtree mean may move from its routing centroid before
+// the centroid is refreshed; <0 disables refreshing.
+func (c *Config) routingDrift() float64 {
+	if c.RoutingDrift == 0 {
+		return defaultRoutingDrift
+	}
+	return c.RoutingDrift
+}
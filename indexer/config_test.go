@@ -0,0 +1,49 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPersist_ConfigRestore(t *testing.T) {
+	build := DefaultConfig()
+	build.VectorsPerBlock = 16
+	build.SplitThreshold = 64
+	build.SearchWidth = 5
+	build.PruneEpsilon = 0.25
+	tree := NewTree(build)
+	defer tree.Pool().Close()
+	for i, v := range randomVectors(200, 91) {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(t.TempDir(), "cfg.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := DefaultConfig()
+	loaded, err := NewTreeFromFile(path, serve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.Config()
+	if got.VectorsPerBlock != 16 || got.SplitThreshold != 64 || got.SearchWidth != 5 || got.PruneEpsilon != 0.25 {
+		t.Errorf("file config not restored: %+v", got)
+	}
+	if serve.VectorsPerBlock != 64 || serve.SearchWidth != 3 {
+		t.Errorf("caller config modified: %+v", serve)
+	}
+	loaded.ClosePersisted()
+
+	serve.ConfigMerge = ConfigFromCaller
+	serve.SearchWidth = 2
+	loaded, err = NewTreeFromFile(path, serve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = loaded.Config()
+	if got.VectorsPerBlock != 16 || got.SplitThreshold != 512 || got.SearchWidth != 2 || got.PruneEpsilon != 0.1 {
+		t.Errorf("caller config not kept: %+v", got)
+	}
+	loaded.ClosePersisted()
+}
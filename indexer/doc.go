@@ -1,6 +1,6 @@
 // Package indexer provides the density-adaptive hierarchical vector routing index (DA-HVRI).
 //
-// Vectors must be 512-dimensional and L2-normalized. Use ShardedIndex for
+// Vectors must be 512-dimensional and L2-normalized (simd.Normalize does it in place). Use ShardedIndex for
 // medium-to-large scale; use Tree for single-tree or small scale.
 //
 // Quick start (build and search):
@@ -0,0 +1,36 @@
+package indexer
+
+// LoadOption configures LoadFrom, LoadFromReaderAt, NewTreeFromFile, NewTreeFromReaderAt and
+// NewShardedIndexFromFiles.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	key []byte // AES key; nil expects a plaintext file
+}
+
+func applyLoadOptions(opts []LoadOption) loadOptions {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithEncryptionKey encrypts the saved file with AES-GCM under key, which must be 16, 24 or 32
+// bytes (AES-128, -192 or -256). The whole file is encrypted, metadata included, so the same key
+// is needed to load it or read its metadata (see WithDecryptionKey). Applies to SaveTo,
+// SaveToAtomic, Checkpoint, Compact and Upgrade; Sync refuses it.
+func WithEncryptionKey(key []byte) SaveOption {
+	return func(o *saveOptions) {
+		o.key = key
+	}
+}
+
+// WithDecryptionKey loads a file saved with WithEncryptionKey(key). Loading a plaintext file with
+// a key fails with store.ErrNotEncrypted, and an encrypted file without one with
+// store.ErrEncrypted; a wrong key or tampered file fails with store.ErrDecrypt.
+func WithDecryptionKey(key []byte) LoadOption {
+	return func(o *loadOptions) {
+		o.key = key
+	}
+}
@@ -0,0 +1,157 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+func TestPersist_Encryption(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	// More than one EncryptedChunkSize of plaintext.
+	vecs := randomVectors(700, 83)
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	key := bytes.Repeat([]byte{0x5a}, 32)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "enc.bin")
+	if err := tree.SaveToAtomic(path, WithEncryptionKey(key), WithMetadata(store.Metadata{"tenant": "acme"})); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsEncrypted(raw) || int64(len(raw)) < store.EncryptedChunkSize {
+		t.Fatalf("not an encrypted container of the expected size: %d bytes", len(raw))
+	}
+	if bytes.Contains(raw, []byte(store.Magic)) || bytes.Contains(raw, []byte("acme")) {
+		t.Fatal("plaintext found in encrypted file")
+	}
+
+	for _, mode := range []LoadMode{LoadMmap, LoadHeap, LoadOffheap, LoadPread} {
+		lc := DefaultConfig()
+		lc.LoadMode = mode
+		loaded, err := NewTreeFromFile(path, lc, WithDecryptionKey(key))
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		checkVectors(t, loaded, vecs)
+		if v, _ := loaded.Metadata().String("tenant"); v != "acme" {
+			t.Fatalf("mode %d: tenant %q", mode, v)
+		}
+		if res := loaded.SearchMultiPath(vecs[5], 1); len(res) != 1 || res[0].ChunkID != 5 {
+			t.Fatalf("mode %d: search got %v", mode, res)
+		}
+		loaded.ClosePersisted()
+		if p := loaded.Pool(); p != nil {
+			p.Close()
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	viaReaderAt, err := NewTreeFromReaderAt(f, int64(len(raw)), nil, WithDecryptionKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVectors(t, viaReaderAt, vecs)
+	viaReaderAt.ClosePersisted()
+
+	if _, err := NewTreeFromFile(path, nil); !errors.Is(err, store.ErrEncrypted) {
+		t.Fatalf("no key: got %v", err)
+	}
+	if _, err := store.ReadMetadata(path); !errors.Is(err, store.ErrEncrypted) {
+		t.Fatalf("metadata without key: got %v", err)
+	}
+	wrong := bytes.Repeat([]byte{0xa5}, 32)
+	if _, err := NewTreeFromFile(path, nil, WithDecryptionKey(wrong)); !errors.Is(err, store.ErrDecrypt) {
+		t.Fatalf("wrong key: got %v", err)
+	}
+	if _, err := NewTreeFromFile(path, nil, WithDecryptionKey(key[:7])); err == nil {
+		t.Fatal("invalid key size accepted")
+	}
+
+	tampered := filepath.Join(dir, "tampered.bin")
+	bad := append([]byte(nil), raw...)
+	bad[len(bad)/2] ^= 1
+	if err := os.WriteFile(tampered, bad, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTreeFromFile(tampered, nil, WithDecryptionKey(key)); !errors.Is(err, store.ErrDecrypt) {
+		t.Fatalf("tampered: got %v", err)
+	}
+	// Dropping the final chunk leaves a file of whole chunks, caught by the last-chunk flag.
+	sealed := store.EncryptedChunkSize + 16
+	if err := os.WriteFile(tampered, raw[:store.EncryptedHeaderSize+sealed], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTreeFromFile(tampered, nil, WithDecryptionKey(key)); !errors.Is(err, store.ErrDecrypt) {
+		t.Fatalf("truncated: got %v", err)
+	}
+
+	// The unauthenticated chunk size is bounded before it sizes any buffer.
+	for _, cs := range []uint32{0, store.EncryptedChunkSize + 1, 1<<32 - 1} {
+		bad := append([]byte(nil), raw[:store.EncryptedHeaderSize+64]...)
+		binary.LittleEndian.PutUint32(bad[8:], cs)
+		if _, err := store.Decrypt(bytes.NewReader(bad), int64(len(bad)), key); !errors.Is(err, store.ErrDecrypt) {
+			t.Fatalf("chunk size %d: got %v", cs, err)
+		}
+	}
+	var enc bytes.Buffer
+	ew, err := store.NewEncryptWriter(&enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ew.Write(make([]byte, 4096))
+	ew.Close()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ds, err := store.Decrypt(bytes.NewReader(enc.Bytes()), int64(enc.Len()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	ds.Close()
+	if d := after.TotalAlloc - before.TotalAlloc; d > 64<<10 {
+		t.Fatalf("decrypting a %d-byte file allocated %d bytes", enc.Len(), d)
+	}
+
+	plain := filepath.Join(dir, "plain.bin")
+	if err := tree.SaveTo(plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTreeFromFile(plain, nil, WithDecryptionKey(key)); !errors.Is(err, store.ErrNotEncrypted) {
+		t.Fatalf("plaintext with key: got %v", err)
+	}
+
+	// Checkpoints to an encrypted file rewrite it whole.
+	ckpt := filepath.Join(dir, "ckpt.bin")
+	for round := 0; round < 2; round++ {
+		tree.Add(randomVectors(1, int64(90+round))[0], uint64(1000+round))
+		if err := tree.Checkpoint(ckpt, WithEncryptionKey(key)); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := NewTreeFromFile(ckpt, nil, WithDecryptionKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(collectVectors(*loaded.Root().Load())); got != len(vecs)+round+1 {
+			t.Fatalf("checkpoint %d: %d vectors", round, got)
+		}
+		loaded.ClosePersisted()
+	}
+}
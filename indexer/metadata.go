@@ -0,0 +1,116 @@
+package indexer
+
+import (
+	"time"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// Metadata keys stamped on every saved index. Caller metadata with the same keys wins.
+const (
+	MetaCreatedAt       = "created_at"               // time.Time of the save
+	MetaUpgradedAt      = "upgraded_at"              // time.Time of the Upgrade; created_at is kept from the source
+	MetaVectorsPerBlock = "config.vectors_per_block" // int64
+	MetaSplitThreshold  = "config.split_threshold"   // int64
+	MetaSearchWidth     = "config.search_width"      // int64
+	MetaPruneEpsilon    = "config.prune_epsilon"     // float64
+)
+
+// SaveOption configures SaveTo, SaveToAtomic, Checkpoint, Compact and Sync.
+type SaveOption func(*saveOptions)
+
+type saveOptions struct {
+	meta    store.Metadata
+	key     []byte // AES key; nil writes plaintext
+	upgrade bool   // stamp only what the source file recorded (see Upgrade)
+}
+
+func applySaveOptions(opts []SaveOption) saveOptions {
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMetadata adds entries to the metadata section of the saved file, e.g. the embedding model
+// name and version or a corpus snapshot ID. Repeated options are merged; later keys win.
+func WithMetadata(m store.Metadata) SaveOption {
+	return func(o *saveOptions) {
+		if o.meta == nil {
+			o.meta = make(store.Metadata, len(m))
+		}
+		for k, v := range m {
+			o.meta[k] = v
+		}
+	}
+}
+
+// Metadata returns a copy of the metadata of the file the tree was loaded from (or last synced
+// to), or nil if it has none.
+func (t *Tree) Metadata() store.Metadata {
+	return t.meta.Clone()
+}
+
+// upgradeSave marks a save as an Upgrade rewrite.
+func upgradeSave() SaveOption {
+	return func(o *saveOptions) { o.upgrade = true }
+}
+
+// buildMetadata merges base (metadata carried over from the file), the build stamps for cfg and
+// the caller's options into the metadata to write.
+func buildMetadata(base store.Metadata, cfg *Config, opts []SaveOption) store.Metadata {
+	o := applySaveOptions(opts)
+	cfg = cfg.OrDefault()
+	m := base.Clone()
+	if m == nil {
+		m = make(store.Metadata)
+	}
+	if o.upgrade {
+		// cfg holds defaults for whatever the source did not record; stamping them would make
+		// ConfigFromFile take them for the build config. Only the block layout comes from the header.
+		m[MetaUpgradedAt] = time.Now().UTC()
+		m[MetaVectorsPerBlock] = int64(cfg.VectorsPerBlock)
+		for k, v := range o.meta {
+			m[k] = v
+		}
+		return m
+	}
+	m[MetaCreatedAt] = time.Now().UTC()
+	m[MetaVectorsPerBlock] = int64(cfg.VectorsPerBlock)
+	m[MetaSplitThreshold] = int64(cfg.SplitThreshold)
+	m[MetaSearchWidth] = int64(cfg.SearchWidth)
+	m[MetaPruneEpsilon] = cfg.PruneEpsilon
+	stampModel(m, cfg.Model)
+	for k, v := range o.meta {
+		m[k] = v
+	}
+	return m
+}
+
+// mergeFileConfig returns a copy of cfg with the block layout of h and, under ConfigFromFile,
+// the build config recorded in meta. cfg is not modified.
+func mergeFileConfig(cfg *Config, h *store.Header, meta store.Metadata) *Config {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	} else {
+		c = *DefaultConfig()
+	}
+	c.OrDefault()
+	if h != nil && h.VectorsPerBlock > 0 {
+		c.VectorsPerBlock = int(h.VectorsPerBlock)
+	}
+	if c.ConfigMerge == ConfigFromFile {
+		if v, ok := meta.Int64(MetaSplitThreshold); ok && v > 0 {
+			c.SplitThreshold = int(v)
+		}
+		if v, ok := meta.Int64(MetaSearchWidth); ok && v > 0 {
+			c.SearchWidth = int(v)
+		}
+		if v, ok := meta.Float64(MetaPruneEpsilon); ok && v >= 0 {
+			c.PruneEpsilon = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+func TestPersist_Metadata(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range randomVectors(100, 71) {
+		tree.Add(v, uint64(i))
+	}
+	built := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "meta.bin")
+	err := tree.SaveTo(path, WithMetadata(store.Metadata{
+		"model":    "bge-small-zh",
+		"revision": 3,
+		"snapshot": []byte{1, 2, 3},
+		"built_at": built,
+	}), WithMetadata(store.Metadata{"normalized": true, "temperature": 0.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(m store.Metadata) {
+		t.Helper()
+		if v, _ := m.String("model"); v != "bge-small-zh" {
+			t.Errorf("model: got %q", v)
+		}
+		if v, _ := m.Int64("revision"); v != 3 {
+			t.Errorf("revision: got %d", v)
+		}
+		if v, _ := m.Bytes("snapshot"); !bytes.Equal(v, []byte{1, 2, 3}) {
+			t.Errorf("snapshot: got %v", v)
+		}
+		if v, _ := m.Time("built_at"); !v.Equal(built) {
+			t.Errorf("built_at: got %v", v)
+		}
+		if v, ok := m.Bool("normalized"); !ok || !v {
+			t.Error("normalized missing")
+		}
+		if v, _ := m.Float64("temperature"); v != 0.5 {
+			t.Errorf("temperature: got %v", v)
+		}
+		if v, _ := m.Int64(MetaSplitThreshold); v != 32 {
+			t.Errorf("split threshold: got %d", v)
+		}
+		if v, ok := m.Time(MetaCreatedAt); !ok || time.Since(v) > time.Hour {
+			t.Errorf("created_at: got %v", v)
+		}
+	}
+	m, err := store.ReadMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(m)
+
+	c := *cfg
+	c.LoadMode = LoadHeap
+	loaded, err := NewTreeFromFile(path, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Pool().Close()
+	check(loaded.Metadata())
+
+	// Metadata loaded with the tree is carried into later saves and checkpoints.
+	ckpt := filepath.Join(dir, "ckpt.bin")
+	if err := loaded.Checkpoint(ckpt, WithMetadata(store.Metadata{"corpus": "2024-05"})); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Add(randomVectors(1, 72)[0], 1000)
+	if err := loaded.Checkpoint(ckpt); err != nil {
+		t.Fatal(err)
+	}
+	m, err = store.ReadMetadata(ckpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(m)
+	if v, _ := m.String("corpus"); v != "2024-05" {
+		t.Errorf("corpus: got %q", v)
+	}
+
+	rw, err := NewTreeWritable(ckpt, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(rw.Metadata())
+	if err := rw.Sync(WithMetadata(store.Metadata{"model": "bge-base-zh"})); err != nil {
+		t.Fatal(err)
+	}
+	rw.ClosePersisted()
+	m, err = store.ReadMetadata(ckpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.String("model"); v != "bge-base-zh" {
+		t.Errorf("model after Sync: got %q", v)
+	}
+
+	if _, err := store.EncodeMetadata(store.Metadata{"bad": struct{}{}}); err == nil {
+		t.Error("expected error for unsupported metadata type")
+	}
+}
@@ -0,0 +1,185 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sync/atomic"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// ErrModelMismatch is returned (wrapped) when an index was built with a different embedding model.
+var ErrModelMismatch = errors.New("embedding model mismatch")
+
+// Metadata keys of the model fingerprint, written when Config.Model is set.
+const (
+	MetaModelName          = "model.name"           // string
+	MetaModelDim           = "model.dim"            // int64
+	MetaModelProbeChecksum = "model.probe_checksum" // uint64
+)
+
+// ModelFingerprint identifies the embedding model whose vectors an index holds. Zero fields are
+// unknown and match anything.
+type ModelFingerprint struct {
+	Name          string // model name and version, e.g. "bge-small-zh-v1.5"
+	Dim           int    // embedding dimension before any projection to BlockDim
+	ProbeChecksum uint64 // ProbeChecksum of the model's embeddings of a fixed set of probe texts
+}
+
+// IsZero reports whether nothing is known about the model.
+func (m ModelFingerprint) IsZero() bool {
+	return m == ModelFingerprint{}
+}
+
+// Check returns an error wrapping ErrModelMismatch if m and other are known to differ.
+func (m ModelFingerprint) Check(other ModelFingerprint) error {
+	switch {
+	case m.Name != "" && other.Name != "" && m.Name != other.Name:
+		return fmt.Errorf("%w: model %q vs %q", ErrModelMismatch, m.Name, other.Name)
+	case m.Dim != 0 && other.Dim != 0 && m.Dim != other.Dim:
+		return fmt.Errorf("%w: dimension %d vs %d", ErrModelMismatch, m.Dim, other.Dim)
+	case m.ProbeChecksum != 0 && other.ProbeChecksum != 0 && m.ProbeChecksum != other.ProbeChecksum:
+		return fmt.Errorf("%w: probe checksum %#x vs %#x", ErrModelMismatch, m.ProbeChecksum, other.ProbeChecksum)
+	}
+	return nil
+}
+
+// ProbeChecksum hashes the embeddings of a fixed, ordered set of probe texts. Values are rounded
+// to 1e-4 first so numerical noise across hardware does not change the checksum, while a
+// different model or revision almost surely does. Never returns 0.
+func ProbeChecksum(embeddings [][]float32) uint64 {
+	h := fnv.New64a()
+	var b [4]byte
+	for _, e := range embeddings {
+		for _, v := range e {
+			q := uint32(int32(math.Round(float64(v) * 1e4)))
+			b[0], b[1], b[2], b[3] = byte(q), byte(q>>8), byte(q>>16), byte(q>>24)
+			h.Write(b[:])
+		}
+		h.Write([]byte{0xff}) // separate embeddings
+	}
+	if sum := h.Sum64(); sum != 0 {
+		return sum
+	}
+	return 1
+}
+
+// ModelMismatchPolicy selects what loading does when the file's model differs from Config.Model.
+type ModelMismatchPolicy int
+
+const (
+	// ModelMismatchReject fails the load with ErrModelMismatch (default).
+	ModelMismatchReject ModelMismatchPolicy = iota
+	// ModelMismatchWarn loads anyway and reports the mismatch to Config.OnModelMismatch
+	// (or the standard logger when nil).
+	ModelMismatchWarn
+)
+
+// mergeModel fills the unknown fields of a from b.
+func mergeModel(a, b ModelFingerprint) ModelFingerprint {
+	if a.Name == "" {
+		a.Name = b.Name
+	}
+	if a.Dim == 0 {
+		a.Dim = b.Dim
+	}
+	if a.ProbeChecksum == 0 {
+		a.ProbeChecksum = b.ProbeChecksum
+	}
+	return a
+}
+
+// modelFromMetadata returns the fingerprint recorded in m.
+func modelFromMetadata(m store.Metadata) ModelFingerprint {
+	var f ModelFingerprint
+	f.Name, _ = m.String(MetaModelName)
+	if d, ok := m.Int64(MetaModelDim); ok {
+		f.Dim = int(d)
+	}
+	f.ProbeChecksum, _ = m.Uint64(MetaModelProbeChecksum)
+	return f
+}
+
+// stampModel records the known fields of f that m does not have yet. A model already recorded
+// in m (carried over from the loaded file) describes the stored vectors and is kept.
+func stampModel(m store.Metadata, f ModelFingerprint) {
+	if _, ok := m[MetaModelName]; !ok && f.Name != "" {
+		m[MetaModelName] = f.Name
+	}
+	if _, ok := m[MetaModelDim]; !ok && f.Dim != 0 {
+		m[MetaModelDim] = int64(f.Dim)
+	}
+	if _, ok := m[MetaModelProbeChecksum]; !ok && f.ProbeChecksum != 0 {
+		m[MetaModelProbeChecksum] = f.ProbeChecksum
+	}
+}
+
+// Model returns the fingerprint of the embedding model of the tree's vectors: the one recorded in
+// the file it was loaded from, completed by Config.Model.
+func (t *Tree) Model() ModelFingerprint {
+	return mergeModel(modelFromMetadata(t.meta), t.cfg.Model)
+}
+
+// CheckModel returns an error wrapping ErrModelMismatch if queries embedded with m must not be
+// searched against the tree. Call it where queries enter, e.g. once per client or model reload.
+func (t *Tree) CheckModel(m ModelFingerprint) error {
+	return t.Model().Check(m)
+}
+
+// checkLoadedModel applies cfg.ModelMismatch to the model recorded in a file being loaded.
+func checkLoadedModel(cfg *Config, meta store.Metadata) error {
+	return applyModelPolicy(cfg, modelFromMetadata(meta).Check(cfg.Model))
+}
+
+// applyModelPolicy returns a model mismatch err under ModelMismatchReject; under
+// ModelMismatchWarn it reports err and returns nil.
+func applyModelPolicy(cfg *Config, err error) error {
+	if err == nil || cfg.ModelMismatch == ModelMismatchReject {
+		return err
+	}
+	if cfg.OnModelMismatch != nil {
+		cfg.OnModelMismatch(err)
+	} else {
+		log.Printf("indexer: %v", err)
+	}
+	return nil
+}
+
+// queryModelGuard applies Config.ModelMismatch to the models queries are embedded with. Under
+// ModelMismatchWarn it reports a mismatching query model once, not on every search.
+type queryModelGuard struct {
+	warned atomic.Pointer[ModelFingerprint] // last query model reported
+}
+
+func (g *queryModelGuard) check(cfg *Config, have, query ModelFingerprint) error {
+	err := have.Check(query)
+	if err == nil || cfg.ModelMismatch == ModelMismatchReject {
+		return err
+	}
+	if last := g.warned.Load(); last != nil && *last == query {
+		return nil
+	}
+	g.warned.Store(&query)
+	return applyModelPolicy(cfg, err)
+}
+
+// SearchChecked is Search for queries embedded with model: under ModelMismatchReject it returns
+// an error wrapping ErrModelMismatch instead of searching if the tree's model differs (see
+// CheckModel); under ModelMismatchWarn it reports the mismatch once and searches.
+func (t *Tree) SearchChecked(query []float32, k int, model ModelFingerprint) ([]SearchResult, error) {
+	if err := t.queryModel.check(t.cfg, t.Model(), model); err != nil {
+		return nil, err
+	}
+	return t.Search(query, k), nil
+}
+
+// SearchMultiPathChecked is SearchMultiPath with the model check of SearchChecked.
+func (t *Tree) SearchMultiPathChecked(query []float32, k int, model ModelFingerprint) ([]SearchResult, error) {
+	if err := t.queryModel.check(t.cfg, t.Model(), model); err != nil {
+		return nil, err
+	}
+	return t.SearchMultiPath(query, k), nil
+}
@@ -0,0 +1,135 @@
+package indexer
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchChecked_ModelMismatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Model = ModelFingerprint{Name: "bge-small-zh-v1.5", Dim: 512}
+	vecs := randomVectors(50, 87)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	other := ModelFingerprint{Name: "text-embedding-3-small"}
+	if res, err := tree.SearchChecked(vecs[3], 1, other); !errors.Is(err, ErrModelMismatch) || res != nil {
+		t.Fatalf("mismatching query model: got %v, %v", res, err)
+	}
+	if _, err := tree.SearchMultiPathChecked(vecs[3], 1, other); !errors.Is(err, ErrModelMismatch) {
+		t.Fatalf("mismatching query model (multi-path): got %v", err)
+	}
+	// A matching or unknown query model searches normally.
+	for _, m := range []ModelFingerprint{cfg.Model, {Dim: 512}, {}} {
+		res, err := tree.SearchChecked(vecs[3], 1, m)
+		if err != nil || len(res) != 1 || res[0].ChunkID != 3 {
+			t.Fatalf("query model %+v: got %v, %v", m, res, err)
+		}
+	}
+
+	// Under ModelMismatchWarn a query model is reported once and searched anyway.
+	var reports int
+	cfg.ModelMismatch = ModelMismatchWarn
+	cfg.OnModelMismatch = func(err error) {
+		if !errors.Is(err, ErrModelMismatch) {
+			t.Errorf("reported %v", err)
+		}
+		reports++
+	}
+	for i := 0; i < 3; i++ {
+		res, err := tree.SearchMultiPathChecked(vecs[3], 1, other)
+		if err != nil || len(res) != 1 || res[0].ChunkID != 3 {
+			t.Fatalf("warn: got %v, %v", res, err)
+		}
+	}
+	tree.SearchChecked(vecs[3], 1, ModelFingerprint{Dim: 768})
+	if reports != 2 {
+		t.Fatalf("%d mismatch reports, want one per query model", reports)
+	}
+}
+
+func TestPersist_ModelFingerprint(t *testing.T) {
+	probe := randomVectors(4, 81)
+	bge := ModelFingerprint{Name: "bge-small-zh-v1.5", Dim: 512, ProbeChecksum: ProbeChecksum(probe)}
+	dir := t.TempDir()
+	build := func(name string, m ModelFingerprint, seed int64) string {
+		cfg := DefaultConfig()
+		cfg.Model = m
+		tree := NewTree(cfg)
+		defer tree.Pool().Close()
+		for i, v := range randomVectors(50, seed) {
+			tree.Add(v, uint64(i))
+		}
+		path := filepath.Join(dir, name)
+		if err := tree.SaveTo(path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := build("a.bin", bge, 1)
+	b := build("b.bin", bge, 2)
+	other := build("other.bin", ModelFingerprint{Name: "text-embedding-3-small", Dim: 1536}, 3)
+	unknown := build("unknown.bin", ModelFingerprint{}, 4)
+
+	cfg := DefaultConfig()
+	cfg.Model = bge
+	tree, err := NewTreeFromFile(a, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Model() != bge {
+		t.Errorf("model: got %+v", tree.Model())
+	}
+	if err := tree.CheckModel(ModelFingerprint{Name: "bge-small-zh-v1.5"}); err != nil {
+		t.Error(err)
+	}
+	// Rounding noise in probe embeddings keeps the checksum.
+	noisy := randomVectors(4, 81)
+	noisy[0][0] += 1e-7
+	if err := tree.CheckModel(ModelFingerprint{ProbeChecksum: ProbeChecksum(noisy)}); err != nil {
+		t.Error(err)
+	}
+	if err := tree.CheckModel(ModelFingerprint{ProbeChecksum: ProbeChecksum(randomVectors(4, 82))}); !errors.Is(err, ErrModelMismatch) {
+		t.Errorf("expected probe mismatch, got %v", err)
+	}
+	tree.ClosePersisted()
+
+	if _, err := NewTreeFromFile(other, cfg); !errors.Is(err, ErrModelMismatch) {
+		t.Errorf("expected ErrModelMismatch, got %v", err)
+	}
+	if tree, err := NewTreeFromFile(unknown, cfg); err != nil {
+		t.Errorf("file without model should load: %v", err)
+	} else {
+		tree.ClosePersisted()
+	}
+	warn := *cfg
+	warn.ModelMismatch = ModelMismatchWarn
+	var warned error
+	warn.OnModelMismatch = func(err error) { warned = err }
+	tree, err = NewTreeFromFile(other, &warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(warned, ErrModelMismatch) {
+		t.Errorf("expected warning, got %v", warned)
+	}
+	if tree.Model().Name != "text-embedding-3-small" {
+		t.Errorf("file model should win: %+v", tree.Model())
+	}
+	tree.ClosePersisted()
+
+	idx, err := NewShardedIndexFromFiles([]string{a, b, unknown}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.SearchMultiPath(randomVectors(1, 1)[0], 3)) == 0 {
+		t.Error("sharded search returned nothing")
+	}
+	idx.ClosePersisted()
+	if _, err := NewShardedIndexFromFiles([]string{a, other}, nil); !errors.Is(err, ErrModelMismatch) {
+		t.Errorf("expected mixed shards to be refused, got %v", err)
+	}
+}
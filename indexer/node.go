@@ -2,6 +2,7 @@ package indexer
 
 import (
 	"github.com/ic-timon/da-hvri/simd"
+	"math"
 	"sync/atomic"
 )
 
@@ -13,13 +14,18 @@ type Node interface {
 	Centroid() []float32
 }
 
-// LeafNode is a leaf node holding Blocks, up to SplitThreshold vectors.
+// LeafNode is a leaf node holding Blocks, up to SplitThreshold vectors (more in overflow mode).
 type LeafNode struct {
 	cfg         *Config
 	blocks      []Block
 	ids         []uint64
 	centroid    []float32
+	sum         []float32 // running sum of the vectors; nil until rebuilt (e.g. after a load)
 	vectorCount int
+	epoch       uint64 // tree epoch that may write this node; older nodes belong to a Snapshot
+	// overflow is set on a full leaf whose vectors are all one exact duplicate, which no split
+	// can separate; it then takes further copies beyond SplitThreshold (see Tree.addToNode).
+	overflow bool
 }
 
 // NewLeafNode creates an empty leaf node.
@@ -34,6 +40,7 @@ func NewLeafNode(pool *Pool, cfg *Config) *LeafNode {
 		blocks:      make([]Block, 0, maxBlocks),
 		ids:         make([]uint64, 0, cfg.SplitThreshold),
 		centroid:    make([]float32, BlockDim),
+		sum:         make([]float32, BlockDim),
 		vectorCount: 0,
 	}
 }
@@ -47,39 +54,104 @@ func (n *LeafNode) Centroid() []float32 { return n.centroid }
 // VectorCount returns the number of vectors in the leaf.
 func (n *LeafNode) VectorCount() int { return n.vectorCount }
 
-// Add appends a vector. Returns false if split is required (at SplitThreshold).
+// Add appends a vector. Returns false if split is required (at SplitThreshold) and the leaf is
+// not in overflow mode.
 func (n *LeafNode) Add(pool *Pool, vec []float32, chunkID uint64) bool {
 	vpb := n.cfg.VectorsPerBlock
 	thresh := n.cfg.SplitThreshold
-	if len(vec) != BlockDim || n.vectorCount >= thresh {
+	if len(vec) != BlockDim || (n.vectorCount >= thresh && !n.overflow) {
 		return false
 	}
 	blockIdx := n.vectorCount / vpb
 	slot := n.vectorCount % vpb
 	if slot == 0 {
-		n.blocks = append(n.blocks, pool.AllocBlock())
+		b := pool.AllocBlock()
+		if b == nil {
+			return false
+		}
+		n.blocks = append(n.blocks, b)
 	}
 	n.blocks[blockIdx].SetVector(slot, vec)
 	n.ids = append(n.ids, chunkID)
 	n.vectorCount++
-	n.updateCentroid()
+	n.addToCentroid(vec)
 	return true
 }
 
-func (n *LeafNode) updateCentroid() {
+// vector returns stored vector i, aliasing the block.
+func (n *LeafNode) vector(i int) []float32 {
+	vpb := n.cfg.VectorsPerBlock
+	d := n.blocks[i/vpb].Data()
+	s := i % vpb
+	return d[s*BlockDim : (s+1)*BlockDim]
+}
+
+// duplicatesOnly reports whether every stored vector is bitwise equal to the first.
+func (n *LeafNode) duplicatesOnly() bool {
 	if n.vectorCount == 0 {
+		return false
+	}
+	first := n.vector(0)
+	for i := 1; i < n.vectorCount; i++ {
+		if !sameVector(n.vector(i), first) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameVector(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Float32bits(a[i]) != math.Float32bits(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// centroidResyncInterval is how many Adds a leaf's running sum absorbs between exact
+// recomputations, bounding the float32 rounding it accumulates.
+const centroidResyncInterval = 256
+
+// addToCentroid folds the just-stored vec into the running sum and rescales the centroid, O(dim)
+// per Add. Every centroidResyncInterval vectors, and whenever the sum is missing, it recomputes
+// the sum exactly from the blocks instead.
+func (n *LeafNode) addToCentroid(vec []float32) {
+	if n.sum == nil || n.vectorCount%centroidResyncInterval == 0 {
+		n.resyncCentroid()
 		return
 	}
+	simd.AddInto(n.sum, vec)
+	simd.ScaleInto(n.centroid, n.sum, float32(1/float64(n.vectorCount)))
+}
+
+// resyncCentroid recomputes the running sum and the centroid from the leaf's vectors,
+// accumulating in float64 so the result is the correctly rounded mean.
+func (n *LeafNode) resyncCentroid() {
+	if n.vectorCount == 0 {
+		return
+	}
+	var acc [BlockDim]float64
 	vpb := n.cfg.VectorsPerBlock
-	for i := 0; i < BlockDim; i++ {
-		var sum float32
-		for b := 0; b < len(n.blocks); b++ {
-			d := n.blocks[b].Data()
-			for s := 0; s < vpb && b*vpb+s < n.vectorCount; s++ {
-				sum += d[s*BlockDim+i]
+	for b := 0; b < len(n.blocks); b++ {
+		d := n.blocks[b].Data()
+		for s := 0; s < vpb && b*vpb+s < n.vectorCount; s++ {
+			row := d[s*BlockDim : (s+1)*BlockDim]
+			for i, x := range row {
+				acc[i] += float64(x)
 			}
 		}
-		n.centroid[i] = sum / float32(n.vectorCount)
+	}
+	if n.sum == nil {
+		n.sum = make([]float32, BlockDim)
+	}
+	inv := 1 / float64(n.vectorCount)
+	for i, s := range acc {
+		n.sum[i] = float32(s)
+		n.centroid[i] = float32(s * inv)
 	}
 }
 
@@ -90,107 +162,132 @@ type SearchResult struct {
 }
 
 // scanAndTopK 扫描块内向量，返回 Top-K 的 (chunkID, score)
+// 逐块打分后立即并入有界小顶堆，低于当前第 k 名的候选直接丢弃，不物化整个叶子的得分数组。
+// bufs 非 nil 时复用其中的堆与结果 buffer（结果在下次扫描前有效），不分配内存
 func (n *LeafNode) scanAndTopK(query []float32, k int, bufs *workerBufs) []SearchResult {
-	if n.vectorCount == 0 {
+	if n.vectorCount == 0 || k <= 0 {
 		return nil
 	}
 	vpb := n.cfg.VectorsPerBlock
-	var scores []float64
-	var indices []int
+	var scratch []float64
+	var h *topKHeap
 	if bufs != nil {
-		if cap(bufs.scores) < n.vectorCount {
-			bufs.scores = make([]float64, n.vectorCount)
-		}
-		scores = bufs.scores[:n.vectorCount]
-		if cap(bufs.indices) < n.vectorCount {
-			bufs.indices = make([]int, n.vectorCount)
+		if cap(bufs.scores) < vpb {
+			bufs.scores = make([]float64, vpb)
 		}
-		indices = bufs.indices[:n.vectorCount]
+		scratch = bufs.scores[:vpb]
+		h = &bufs.heap
 	} else {
-		scores = make([]float64, n.vectorCount)
-		indices = make([]int, n.vectorCount)
+		scratch = make([]float64, vpb)
+		h = new(topKHeap)
 	}
+	h.reset(min(k, n.vectorCount))
+	dist := n.cfg.prefetchBlocks()
 	offset := 0
 	for bi, b := range n.blocks {
-		if bi+1 < len(n.blocks) {
-			if d := n.blocks[bi+1].Data(); len(d) > 0 {
-				_ = d[0]
-			}
-		}
-		if bi+2 < len(n.blocks) {
-			if d := n.blocks[bi+2].Data(); len(d) > 0 {
-				_ = d[0]
-			}
-		}
+		n.prefetchAhead(bi, dist)
 		nInBlock := vpb
 		if offset+nInBlock > n.vectorCount {
 			nInBlock = n.vectorCount - offset
 		}
-		batch := b.DotProductBatch(query, nInBlock)
-		copy(scores[offset:], batch)
+		if scores := b.DotProductBatchInto(query, nInBlock, scratch); scores != nil {
+			h.pushBlock(offset, scores)
+		}
 		offset += nInBlock
 	}
-	return topKFromScores(n.ids, scores, k, indices)
+	if bufs != nil {
+		bufs.results = n.heapResults(h, bufs.results)
+		return bufs.results
+	}
+	return n.heapResults(h, nil)
 }
 
 // scanAndTopKBatch scans blocks once, computes dot products for all queries, returns one []SearchResult per query.
+// Each block is scored against all queries with simd.DotProductBatchMulti, so it is read from memory
+// once per tile of queries rather than once per query; the block's score tile is then merged into
+// one bounded heap per query. The results reuse bufs and are valid until the next scan.
 func (n *LeafNode) scanAndTopKBatch(queries [][]float32, k int, bufs *workerBufs) [][]SearchResult {
-	if n.vectorCount == 0 || len(queries) == 0 {
+	if n.vectorCount == 0 || len(queries) == 0 || k <= 0 {
 		return nil
 	}
 	bufs.ensureBatch(len(queries))
 	vpb := n.cfg.VectorsPerBlock
-	// Ensure each batch score/indices has enough capacity
-	for i := 0; i < len(queries); i++ {
-		if cap(bufs.batchScores[i]) < n.vectorCount {
-			bufs.batchScores[i] = make([]float64, n.vectorCount)
-		}
-		bufs.batchScores[i] = bufs.batchScores[i][:n.vectorCount]
-		if cap(bufs.batchIndices[i]) < n.vectorCount {
-			bufs.batchIndices[i] = make([]int, n.vectorCount)
-		}
-		bufs.batchIndices[i] = bufs.batchIndices[i][:n.vectorCount]
+	if need := len(queries) * vpb; cap(bufs.batchTile) < need {
+		bufs.batchTile = make([]float64, need)
 	}
+	for q := range queries {
+		bufs.batchHeaps[q].reset(min(k, n.vectorCount))
+	}
+	dist := n.cfg.prefetchBlocks()
 	offset := 0
 	for bi, b := range n.blocks {
-		if bi+1 < len(n.blocks) {
-			if d := n.blocks[bi+1].Data(); len(d) > 0 {
-				_ = d[0]
-			}
-		}
-		if bi+2 < len(n.blocks) {
-			if d := n.blocks[bi+2].Data(); len(d) > 0 {
-				_ = d[0]
-			}
-		}
+		n.prefetchAhead(bi, dist)
 		nInBlock := vpb
 		if offset+nInBlock > n.vectorCount {
 			nInBlock = n.vectorCount - offset
 		}
-		for q, query := range queries {
-			batch := b.DotProductBatch(query, nInBlock)
-			copy(bufs.batchScores[q][offset:], batch)
+		tile := simd.DotProductBatchMulti(queries, b.Data(), nInBlock, bufs.batchTile[:cap(bufs.batchTile)])
+		if tile != nil {
+			for q := range queries {
+				bufs.batchHeaps[q].pushBlock(offset, tile[q*nInBlock:(q+1)*nInBlock])
+			}
 		}
 		offset += nInBlock
 	}
-	out := make([][]SearchResult, len(queries))
 	for q := range queries {
-		out[q] = topKFromScores(n.ids, bufs.batchScores[q], k, bufs.batchIndices[q])
+		bufs.batchResults[q] = n.heapResults(&bufs.batchHeaps[q], bufs.batchResults[q])
+	}
+	return bufs.batchResults[:len(queries)]
+}
+
+// prefetchAhead issues cache prefetches for the head of the block dist ahead of block bi, so its
+// first lines are arriving when the scan reaches it; the hardware prefetcher follows the rest.
+// Prefetching whole blocks competes with the current block's loads (see BenchmarkPrefetchScan).
+// Blocks of a store that reads on demand (LoadPread) are not resident, and Data would read them
+// synchronously; the store is asked to start reading them in the background instead.
+func (n *LeafNode) prefetchAhead(bi, dist int) {
+	if dist <= 0 || bi+dist >= len(n.blocks) {
+		return
+	}
+	b := n.blocks[bi+dist]
+	if mb, ok := b.(*DataBlockMmap); ok && mb.prefetcher != nil {
+		mb.prefetcher.Prefetch([]int64{mb.offset}, mb.FloatsPerBlock())
+		return
+	}
+	if d := b.Data(); len(d) >= BlockDim {
+		simd.Prefetch(d[:BlockDim])
+	}
+}
+
+// heapResults drains h best first into dst[:0] as (chunkID, score) pairs.
+func (n *LeafNode) heapResults(h *topKHeap, dst []SearchResult) []SearchResult {
+	out := dst[:0]
+	for _, it := range h.sorted() {
+		out = append(out, SearchResult{ChunkID: n.ids[it.idx], Score: it.score})
 	}
 	return out
 }
 
 // InternalNode is an internal node with 2~N children and centroid list.
+// The routing centroids are one row-major matrix, row i for child i, so a node is routed with a
+// single simd.DotProductBatchFlat call. A published matrix is never written: refreshing a row
+// (see absorb) stores a new matrix, so searches read a consistent one without locking. A
+// read-only load uses the matrix straight from the mapped file.
 type InternalNode struct {
 	children  []atomic.Pointer[Node]
-	centroids [][]float32
+	centroids atomic.Pointer[[]float32] // [len(children)][BlockDim]
+	epoch     uint64                    // tree epoch that may write this node; older nodes belong to a Snapshot
+
+	// Writer-only (under Tree.mu): running sum and count of the vectors in each child's subtree,
+	// from which the routing rows are refreshed. nil until the first Add through the node.
+	sums   []float32 // [len(children)][BlockDim]
+	counts []int
 }
 
 // NewInternalNode creates an internal node.
 func NewInternalNode() *InternalNode {
 	return &InternalNode{
-		children:  make([]atomic.Pointer[Node], 0),
-		centroids: make([][]float32, 0),
+		children: make([]atomic.Pointer[Node], 0),
 	}
 }
 
@@ -199,39 +296,125 @@ func (*InternalNode) IsLeaf() bool { return false }
 
 // Centroid returns the first child's centroid for routing.
 func (n *InternalNode) Centroid() []float32 {
-	if len(n.centroids) == 0 {
+	return n.centroid(0)
+}
+
+// centroidMatrix returns the published routing matrix. The caller must not write to it.
+func (n *InternalNode) centroidMatrix() []float32 {
+	if p := n.centroids.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// setCentroidMatrix publishes m as the routing matrix; m must not be written afterwards.
+func (n *InternalNode) setCentroidMatrix(m []float32) {
+	n.centroids.Store(&m)
+}
+
+// centroid returns the routing centroid of child i, capped so appending to it cannot touch row i+1.
+func (n *InternalNode) centroid(i int) []float32 {
+	m := n.centroidMatrix()
+	if i < 0 || (i+1)*BlockDim > len(m) {
 		return nil
 	}
-	return n.centroids[0]
+	return m[i*BlockDim : (i+1)*BlockDim : (i+1)*BlockDim]
 }
 
 // AddChild adds a child node.
 func (n *InternalNode) AddChild(child Node) {
-	var p atomic.Pointer[Node]
+	n.addChild(child, child.Centroid())
+}
+
+// addChild adds a child node routed by the given centroid, which is copied into a new matrix.
+func (n *InternalNode) addChild(child Node, centroid []float32) {
+	n.appendChild(child)
+	m := n.centroidMatrix()
+	n.setCentroidMatrix(append(m[:len(m):len(m)], centroid...))
+}
+
+// appendChild adds a child slot only; the caller supplies its centroid row.
+func (n *InternalNode) appendChild(child Node) {
 	np := new(Node)
 	*np = child
-	p.Store(np)
-	n.children = append(n.children, p)
-	n.centroids = append(n.centroids, copyVec(child.Centroid()))
+	n.children = append(n.children, atomic.Pointer[Node]{})
+	n.children[len(n.children)-1].Store(np)
 }
 
 // BestChild returns the index of the child with highest dot product to query.
 func (n *InternalNode) BestChild(query []float32) int {
-	if len(n.centroids) == 0 {
+	m := n.centroidMatrix()
+	scores := simd.DotProductBatchFlat(query, m, len(m)/BlockDim)
+	if len(scores) == 0 {
 		return -1
 	}
 	best := 0
-	bestScore := simd.DotProduct(query, n.centroids[0])
-	for i := 1; i < len(n.centroids); i++ {
-		s := simd.DotProduct(query, n.centroids[i])
-		if s > bestScore {
-			bestScore = s
+	for i := 1; i < len(scores); i++ {
+		if scores[i] > scores[best] {
 			best = i
 		}
 	}
 	return best
 }
 
+// absorb records that vec was added under child i and republishes that child's routing row once
+// the subtree mean has drifted more than drift (L2) from it; drift < 0 leaves the rows as they
+// were at the split. Rows are compared and replaced only on this insert path, O(dim) per level.
+// scratch holds BlockDim floats. Caller holds Tree.mu and n is writable.
+func (n *InternalNode) absorb(i int, vec []float32, drift float64, scratch []float32) {
+	if drift < 0 {
+		return
+	}
+	if i < 0 || i >= len(n.children) {
+		return
+	}
+	// Seeding reads the subtree after vec reached it, so a fresh seed already counts vec.
+	seeded := n.ensureSums()
+	sum := n.sums[i*BlockDim : (i+1)*BlockDim]
+	if !seeded {
+		simd.AddInto(sum, vec)
+		n.counts[i]++
+	}
+	mean := scratch[:BlockDim]
+	simd.ScaleInto(mean, sum, float32(1/float64(n.counts[i])))
+	if simd.L2Squared(mean, n.centroid(i)) <= drift*drift {
+		return
+	}
+	m := append([]float32(nil), n.centroidMatrix()...)
+	copy(m[i*BlockDim:], mean)
+	n.setCentroidMatrix(m)
+}
+
+// ensureSums seeds the per-child sums and counts the first time a vector is added through n:
+// from a leaf child's centroid and count, or from an internal child's own (recursively seeded)
+// sums. Centroids loaded from a file are thus refreshed from the leaves, not the saved rows.
+// It reports whether it seeded.
+func (n *InternalNode) ensureSums() bool {
+	if n.sums != nil {
+		return false
+	}
+	sums := make([]float32, len(n.children)*BlockDim)
+	counts := make([]int, len(n.children))
+	for i := range n.children {
+		row := sums[i*BlockDim : (i+1)*BlockDim]
+		switch c := n.Child(i).(type) {
+		case *LeafNode:
+			counts[i] = c.vectorCount
+			if c.vectorCount > 0 {
+				simd.ScaleInto(row, c.centroid, float32(c.vectorCount))
+			}
+		case *InternalNode:
+			c.ensureSums()
+			for j, cnt := range c.counts {
+				counts[i] += cnt
+				simd.AddInto(row, c.sums[j*BlockDim:(j+1)*BlockDim])
+			}
+		}
+	}
+	n.sums, n.counts = sums, counts
+	return true
+}
+
 // Child returns the i-th child node.
 func (n *InternalNode) Child(i int) Node {
 	if i < 0 || i >= len(n.children) {
@@ -252,36 +435,6 @@ func (n *InternalNode) ChildSlot(i int) *atomic.Pointer[Node] {
 	return &n.children[i]
 }
 
-func topKFromScores(ids []uint64, scores []float64, k int, indices []int) []SearchResult {
-	if len(ids) != len(scores) || k <= 0 {
-		return nil
-	}
-	if k > len(ids) {
-		k = len(ids)
-	}
-	if indices == nil || len(indices) < len(ids) {
-		indices = make([]int, len(ids))
-	}
-	indices = indices[:len(ids)]
-	for i := range indices {
-		indices[i] = i
-	}
-	for i := 0; i < k; i++ {
-		best := i
-		for j := i + 1; j < len(indices); j++ {
-			if scores[indices[j]] > scores[indices[best]] {
-				best = j
-			}
-		}
-		indices[i], indices[best] = indices[best], indices[i]
-	}
-	out := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
-		out[i] = SearchResult{ChunkID: ids[indices[i]], Score: scores[indices[i]]}
-	}
-	return out
-}
-
 func copyVec(v []float32) []float32 {
 	if v == nil {
 		return nil
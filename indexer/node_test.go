@@ -0,0 +1,119 @@
+package indexer
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanAndTopK_ZeroAlloc(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 512
+	vecs := randomVectors(100, 44)
+	pool := NewPool(cfg.VectorsPerBlock)
+	defer pool.Close()
+	leaf := NewLeafNode(pool, cfg)
+	for i, v := range vecs {
+		leaf.Add(pool, v, uint64(i))
+	}
+	bufs := newWorkerBufs()
+	queries := [][]float32{vecs[3], vecs[40], vecs[77], vecs[99], vecs[5]}
+	want := make([][]SearchResult, len(queries))
+	for i, q := range queries {
+		want[i] = append([]SearchResult(nil), leaf.scanAndTopK(q, 10, nil)...)
+	}
+	if got := leaf.scanAndTopK(queries[1], 10, bufs); !reflect.DeepEqual(got, want[1]) {
+		t.Fatalf("scanAndTopK with bufs = %v, want %v", got, want[1])
+	}
+	for q, r := range leaf.scanAndTopKBatch(queries, 10, bufs) {
+		if len(r) != len(want[q]) || r[0].ChunkID != want[q][0].ChunkID {
+			t.Fatalf("query %d: batch top = %v, want %v", q, r, want[q])
+		}
+	}
+	if n := testing.AllocsPerRun(20, func() { leaf.scanAndTopK(queries[0], 10, bufs) }); n != 0 {
+		t.Errorf("scanAndTopK: %v allocs per run, want 0", n)
+	}
+	if n := testing.AllocsPerRun(20, func() { leaf.scanAndTopKBatch(queries, 10, bufs) }); n != 0 {
+		t.Errorf("scanAndTopKBatch: %v allocs per run, want 0", n)
+	}
+}
+
+func TestLeafCentroid_RunningSum(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 1024
+	vecs := randomVectors(700, 45)
+	pool := NewPool(cfg.VectorsPerBlock)
+	defer pool.Close()
+	leaf := NewLeafNode(pool, cfg)
+	var exact [BlockDim]float64
+	check := func(n int) {
+		t.Helper()
+		for i, x := range leaf.Centroid() {
+			want := exact[i] / float64(n)
+			if math.Abs(float64(x)-want) > 1e-6 {
+				t.Fatalf("after %d adds: centroid[%d] = %g, want %g", n, i, x, want)
+			}
+		}
+	}
+	for i, v := range vecs[:600] {
+		if !leaf.Add(pool, v, uint64(i)) {
+			t.Fatalf("Add failed at %d", i)
+		}
+		for j, x := range v {
+			exact[j] += float64(x)
+		}
+		check(i + 1)
+	}
+	// A leaf without a running sum (as after a load) rebuilds it on the next Add.
+	leaf.sum = nil
+	for i, v := range vecs[600:] {
+		leaf.Add(pool, v, uint64(600+i))
+		for j, x := range v {
+			exact[j] += float64(x)
+		}
+		check(601 + i)
+	}
+
+}
+
+// Leaf-scan prefetch must not read pread blocks itself: a Search looks each scanned block up in
+// the cache exactly once, with or without prefetching.
+func TestPrefetchAhead_PreadReadsOnce(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 64
+	vecs := randomVectors(400, 86)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(t.TempDir(), "pread.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, dist := range []int{0, 1, -1} { // default (2), 1, disabled
+		c := *cfg
+		c.LoadMode = LoadPread
+		c.BlockCacheBlocks = 1024
+		c.PrefetchDistance = dist
+		loaded, err := NewTreeFromFile(path, &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := *loaded.Root().Load()
+		for !n.IsLeaf() {
+			internal := n.(*InternalNode)
+			n = internal.Child(internal.BestChild(vecs[7]))
+		}
+		loaded.Search(vecs[7], 5)
+		stats, _ := loaded.BlockCacheStats()
+		if got, want := stats.Hits+stats.Misses, uint64(len(n.(*LeafNode).blocks)); got != want {
+			t.Errorf("PrefetchDistance %d: %d block lookups (%+v), want %d", dist, got, stats, want)
+		}
+		loaded.ClosePersisted()
+	}
+}
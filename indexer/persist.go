@@ -1,6 +1,7 @@
 package indexer
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
@@ -20,12 +21,28 @@ func alignUp(x, align int64) int64 {
 }
 
 // NewTreeFromFile loads a tree from file (mmap). cfg may be nil to use DefaultConfig().
-// The returned tree is read-only; call ClosePersisted when done.
+// The returned tree is read-only with LoadMmap (the default) and LoadPread, and accepts Add with
+// LoadHeap and LoadOffheap (see NewTreeWritable for a writable mapping); call ClosePersisted when done.
 // If cfg.SearchPoolWorkers > 0, a single-tree search pool is created for high-concurrency throttling.
-func NewTreeFromFile(path string, cfg *Config) (*Tree, error) {
+// Encrypted files need WithDecryptionKey (see LoadFrom).
+func NewTreeFromFile(path string, cfg *Config, opts ...LoadOption) (*Tree, error) {
 	cfg = cfg.OrDefault()
 	t := &Tree{cfg: cfg}
-	if err := t.LoadFrom(path); err != nil {
+	if err := t.LoadFrom(path, opts...); err != nil {
+		return nil, err
+	}
+	if cfg.SearchPoolWorkers > 0 {
+		t.searchPool = newSingleTreeSearchPool(t, cfg.SearchPoolWorkers, 64)
+	}
+	return t, nil
+}
+
+// NewTreeFromReaderAt is NewTreeFromFile for an index of size bytes read through r
+// (see LoadFromReaderAt). Call ClosePersisted when done.
+func NewTreeFromReaderAt(r io.ReaderAt, size int64, cfg *Config, opts ...LoadOption) (*Tree, error) {
+	cfg = cfg.OrDefault()
+	t := &Tree{cfg: cfg}
+	if err := t.LoadFromReaderAt(r, size, opts...); err != nil {
 		return nil, err
 	}
 	if cfg.SearchPoolWorkers > 0 {
@@ -36,9 +53,15 @@ func NewTreeFromFile(path string, cfg *Config) (*Tree, error) {
 
 // SaveToAtomic writes the tree to a file atomically (write to path+".tmp", then rename).
 // On Windows, the target must not exist for Rename to succeed; remove it first.
-func (t *Tree) SaveToAtomic(path string) error {
+// Like SaveTo, it runs off a Snapshot and Add may continue meanwhile.
+func (t *Tree) SaveToAtomic(path string, opts ...SaveOption) error {
+	return t.Snapshot().SaveToAtomic(path, opts...)
+}
+
+// SaveToAtomic writes the snapshot to path+".tmp" and renames it over path.
+func (s *Snapshot) SaveToAtomic(path string, opts ...SaveOption) error {
 	tmp := path + ".tmp"
-	if err := t.SaveTo(tmp); err != nil {
+	if err := s.SaveTo(tmp, opts...); err != nil {
 		return err
 	}
 	_ = os.Remove(path) // ignore error if not exists
@@ -47,26 +70,27 @@ func (t *Tree) SaveToAtomic(path string) error {
 
 // AppendTo loads from path, adds vectors, and saves atomically. Returns the loaded+appended tree.
 // If the file does not exist, creates a new tree with the given vectors.
+// The existing index is loaded with LoadHeap (LoadOffheap when cfg.UseOffheap) so blocks are
+// copied once instead of re-adding every vector.
 func AppendTo(path string, vecs [][]float32, chunkIDs []uint64, cfg *Config) (*Tree, error) {
 	cfg = cfg.OrDefault()
 	var t *Tree
 	if _, err := os.Stat(path); err == nil {
-		loaded, err := NewTreeFromFile(path, cfg)
+		loadCfg := *cfg
+		loadCfg.LoadMode = LoadHeap
+		if cfg.UseOffheap {
+			loadCfg.LoadMode = LoadOffheap
+		}
+		loadCfg.SearchPoolWorkers = 0
+		loaded, err := NewTreeFromFile(path, &loadCfg)
 		if err != nil {
 			return nil, err
 		}
-		// Copy to heap-backed tree (collect before ClosePersisted)
-		existingVecs, existingIds := collectVectorsFromNode(loaded.root.Load())
-		loaded.ClosePersisted()
-		t = NewTree(cfg)
-		for i, v := range existingVecs {
-			if !t.Add(v, existingIds[i]) {
-				return nil, errors.New("add existing vector failed")
-			}
-		}
+		t = loaded
 	} else {
 		t = NewTree(cfg)
 	}
+	defer t.Pool().Close() // t is replaced by the reloaded tree below
 	for i, v := range vecs {
 		id := uint64(i)
 		if i < len(chunkIDs) {
@@ -87,209 +111,413 @@ func AppendTo(path string, vecs [][]float32, chunkIDs []uint64, cfg *Config) (*T
 	return t2, nil
 }
 
-// collectVectorsFromNode collects all vectors and ids from a tree (for migration from mmap to heap).
-func collectVectorsFromNode(n *Node) ([][]float32, []uint64) {
-	if n == nil {
-		return nil, nil
-	}
-	node := *n
-	if node.IsLeaf() {
-		leaf := node.(*LeafNode)
-		vecs := make([][]float32, 0, leaf.vectorCount)
-		ids := make([]uint64, 0, leaf.vectorCount)
-		vpb := leaf.cfg.VectorsPerBlock
-		for bi, b := range leaf.blocks {
-			d := b.Data()
-			if d == nil {
-				continue
-			}
-			nInBlock := vpb
-			if (bi+1)*vpb > leaf.vectorCount {
-				nInBlock = leaf.vectorCount - bi*vpb
-			}
-			for s := 0; s < nInBlock; s++ {
-				v := make([]float32, BlockDim)
-				copy(v, d[s*BlockDim:(s+1)*BlockDim])
-				vecs = append(vecs, v)
-				ids = append(ids, leaf.ids[bi*vpb+s])
+// copyBlocksToPool replaces the blocks of every leaf under n with pool-allocated copies.
+func copyBlocksToPool(n Node, pool *Pool) {
+	if n.IsLeaf() {
+		leaf := n.(*LeafNode)
+		for i, b := range leaf.blocks {
+			nb := pool.AllocBlock()
+			if d := b.Data(); d != nil {
+				copy(nb.Data(), d)
 			}
+			leaf.blocks[i] = nb
 		}
-		return vecs, ids
+		return
 	}
-	internal := node.(*InternalNode)
-	var allVecs [][]float32
-	var allIds []uint64
+	internal := n.(*InternalNode)
 	for i := 0; i < len(internal.children); i++ {
-		child := internal.Child(i)
-		if child != nil {
-			np := new(Node)
-			*np = child
-			v, id := collectVectorsFromNode(np)
-			allVecs = append(allVecs, v...)
-			allIds = append(allIds, id...)
+		if child := internal.Child(i); child != nil {
+			copyBlocksToPool(child, pool)
 		}
 	}
-	return allVecs, allIds
 }
 
-// SaveTo writes the tree to a file. The tree must not be modified during save.
-func (t *Tree) SaveTo(path string) error {
-	root := t.root.Load()
-	if root == nil {
+// SaveTo writes the tree to a file. It saves a Snapshot taken on entry, so Add may continue
+// during the save; vectors added after SaveTo was called are not written.
+// The file's metadata section holds the metadata the tree was loaded with, the build config and
+// creation time (see MetaCreatedAt), and any WithMetadata entries.
+func (t *Tree) SaveTo(path string, opts ...SaveOption) error {
+	return t.Snapshot().SaveTo(path, opts...)
+}
+
+// SaveTo writes the snapshot to a file. An empty snapshot writes nothing.
+// With WithEncryptionKey the file is an AES-GCM container and plaintext never reaches the disk.
+func (s *Snapshot) SaveTo(path string, opts ...SaveOption) error {
+	if s.root == nil {
 		return nil
 	}
-	cfg := t.cfg.OrDefault()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	var out io.Writer = w
+	var enc io.WriteCloser
+	if key := applySaveOptions(opts).key; key != nil {
+		if enc, err = store.NewEncryptWriter(w, key); err != nil {
+			return err
+		}
+		out = enc
+	}
+	if _, err := s.writeTo(out, opts); err != nil {
+		return err
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// WriteTo writes the tree to w in the index file format, e.g. into a tarball or a blob upload.
+// It runs off a Snapshot, so Add may continue. An empty tree writes nothing.
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	return t.Snapshot().WriteTo(w)
+}
+
+// WriteTo writes the snapshot to w in the index file format. The output is written sequentially
+// and can be loaded with LoadFromReaderAt, or mapped once stored in a file.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	return s.writeTo(w, nil)
+}
+
+func (s *Snapshot) writeTo(w io.Writer, opts []SaveOption) (int64, error) {
+	if s.root == nil {
+		return 0, nil
+	}
+	cfg := s.cfg.OrDefault()
+	meta, err := store.EncodeMetadata(buildMetadata(s.meta, cfg, opts))
+	if err != nil {
+		return 0, err
+	}
 
 	var treeBuf bytes.Buffer
-	var blockBuf bytes.Buffer
-	nextBlockID := 0
-	if err := serializeNode(&treeBuf, *root, &blockBuf, &nextBlockID); err != nil {
-		return err
+	var blocks []blockRef
+	if err := serializeNode(&treeBuf, s.root, &blocks); err != nil {
+		return 0, err
 	}
 
 	treeLen := treeBuf.Len()
-	numBlocks := nextBlockID
-	blockData := blockBuf.Bytes()
+	numBlocks := len(blocks)
+	blockSize := blockSizeBytes(cfg.VectorsPerBlock)
 	routingStart := int64(store.HeaderSize) + int64(treeLen)
-	dataStart := alignUp(routingStart+int64(numBlocks)*8, pageAlign)
+	metaStart := routingStart + int64(numBlocks)*8
+	dataStart := alignUp(metaStart+int64(len(meta)), pageAlign)
 
 	h := &store.Header{
 		Dim:             BlockDim,
 		VectorsPerBlock: uint32(cfg.VectorsPerBlock),
-		BlockSizeBytes:  store.BlockSizeBytes,
+		BlockSizeBytes:  uint32(blockSize),
 		NumBlocks:       uint32(numBlocks),
 		TreeLen:         uint32(treeLen),
 		RoutingOffset:   uint64(routingStart),
 		DataOffset:      uint64(dataStart),
+		TreeOffset:      store.HeaderSize,
+		MetaOffset:      uint64(metaStart),
+		MetaLen:         uint32(len(meta)),
 	}
 	headerBytes, err := store.EncodeHeader(h)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return err
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write(headerBytes); err != nil {
+		return cw.n, err
 	}
-	defer f.Close()
-
-	if _, err := f.Write(headerBytes); err != nil {
-		return err
-	}
-	if _, err := f.Write(treeBuf.Bytes()); err != nil {
-		return err
+	if _, err := cw.Write(treeBuf.Bytes()); err != nil {
+		return cw.n, err
 	}
 	// Routing table
 	for i := 0; i < numBlocks; i++ {
-		off := dataStart + int64(i)*int64(store.BlockSizeBytes)
-		if err := binary.Write(f, binary.LittleEndian, uint64(off)); err != nil {
-			return err
+		off := dataStart + int64(i)*blockSize
+		if err := binary.Write(cw, binary.LittleEndian, uint64(off)); err != nil {
+			return cw.n, err
 		}
 	}
+	if _, err := cw.Write(meta); err != nil {
+		return cw.n, err
+	}
 	// Pad to dataStart (4KB aligned)
-	written := int64(store.HeaderSize) + int64(treeLen) + int64(numBlocks)*8
-	padLen := dataStart - written
-	if padLen > 0 {
-		pad := make([]byte, padLen)
-		if _, err := f.Write(pad); err != nil {
-			return err
+	if padLen := dataStart - cw.n; padLen > 0 {
+		if _, err := cw.Write(make([]byte, padLen)); err != nil {
+			return cw.n, err
 		}
 	}
-	n, err := f.Write(blockData)
+	err = writeBlockData(cw, blocks, cfg.VectorsPerBlock)
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// blockSizeBytes returns the on-disk size of a block holding vectorsPerBlock vectors.
+func blockSizeBytes(vectorsPerBlock int) int64 {
+	return int64(vectorsPerBlock) * BlockDim * 4
+}
+
+// indexSections locates the header, tree structure section and routing table in a mapped index
+// file and checks that every routed block lies within the file.
+func indexSections(data []byte) (*store.Header, []byte, []int64, error) {
+	if len(data) < store.HeaderSize {
+		return nil, nil, nil, errors.New("index file too small or Bytes() not available")
+	}
+	h, err := store.DecodeHeader(data[:store.HeaderSize])
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
-	if n != len(blockData) {
-		return errors.New("failed to write full block data")
+	size := int64(len(data))
+	treeStart, routingStart, err := sectionBounds(h, size)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	// Pad to expected total size (some filesystems may require alignment)
-	expectedTotal := dataStart + int64(len(blockData))
-	if pos, _ := f.Seek(0, io.SeekCurrent); pos < expectedTotal {
-		pad := make([]byte, int(expectedTotal-pos))
-		f.Write(pad)
+	routingOffsets, err := decodeRouting(h, data[routingStart:routingStart+int64(h.NumBlocks)*8], size)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return f.Sync()
+	return h, data[treeStart : treeStart+int64(h.TreeLen)], routingOffsets, nil
 }
 
-// LoadFrom loads a tree from a file. The returned tree is read-only (mmap-backed).
-// Caller must call Close on the returned tree's block store when done (via ClosePersisted).
-func (t *Tree) LoadFrom(path string) error {
-	blockStore, err := store.OpenMmap(path)
+// readSections is indexSections for an index of size bytes read through r. The tree structure
+// section and routing table are copied; blocks are not read.
+func readSections(r io.ReaderAt, size int64) (*store.Header, []byte, []int64, error) {
+	if size < store.HeaderSize {
+		return nil, nil, nil, errors.New("index file too small")
+	}
+	hb := make([]byte, store.HeaderSize)
+	if _, err := readFull(r, hb, 0); err != nil {
+		return nil, nil, nil, err
+	}
+	h, err := store.DecodeHeader(hb)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
+	treeStart, routingStart, err := sectionBounds(h, size)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	treeBuf := make([]byte, h.TreeLen)
+	if _, err := readFull(r, treeBuf, treeStart); err != nil {
+		return nil, nil, nil, err
+	}
+	rb := make([]byte, int64(h.NumBlocks)*8)
+	if _, err := readFull(r, rb, routingStart); err != nil {
+		return nil, nil, nil, err
+	}
+	routingOffsets, err := decodeRouting(h, rb, size)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return h, treeBuf, routingOffsets, nil
+}
 
-	data := blockStore.Bytes()
-	if data == nil || len(data) < store.HeaderSize {
-		blockStore.Close()
-		return errors.New("index file too small or Bytes() not available")
+// readFull reads len(buf) bytes at off, accepting io.EOF that accompanies a full read.
+func readFull(r io.ReaderAt, buf []byte, off int64) (int, error) {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return n, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
 	}
+	return n, err
+}
 
-	h, err := store.DecodeHeader(data[:store.HeaderSize])
-	if err != nil {
-		blockStore.Close()
-		return err
+// sectionBounds returns the start of the tree structure section and of the routing table,
+// checking that both lie within a file of size bytes.
+func sectionBounds(h *store.Header, size int64) (treeStart, routingStart int64, err error) {
+	treeStart = h.TreeStart()
+	routingStart = int64(h.RoutingOffset)
+	if !inFile(treeStart, int64(h.TreeLen), size) || !inFile(routingStart, int64(h.NumBlocks)*8, size) {
+		return 0, 0, errors.New("index file truncated")
+	}
+	return treeStart, routingStart, nil
+}
+
+// decodeRouting decodes the routing table and checks that every block lies within size bytes.
+func decodeRouting(h *store.Header, routing []byte, size int64) ([]int64, error) {
+	blockSize := int64(h.BlockSizeBytes)
+	if blockSize <= 0 {
+		blockSize = store.BlockSizeBytes
+	}
+	routingOffsets := make([]int64, h.NumBlocks)
+	for i := range routingOffsets {
+		off := int64(binary.LittleEndian.Uint64(routing[i*8:]))
+		if !inFile(off, blockSize, size) {
+			return nil, errors.New("index file truncated")
+		}
+		routingOffsets[i] = off
 	}
+	return routingOffsets, nil
+}
 
-	treeStart := int64(store.HeaderSize)
-	treeEnd := treeStart + int64(h.TreeLen)
-	if int64(len(data)) < treeEnd {
-		blockStore.Close()
-		return nil
+// inFile reports whether the n bytes at off lie within a file of size bytes. Header offsets are
+// uint64 on disk, so off may be negative as int64 or near its limit; it is checked before n is
+// added.
+func inFile(off, n, size int64) bool {
+	return off >= 0 && n >= 0 && off <= size && n <= size-off
+}
+
+// LoadFrom loads a tree from a file. With the default LoadMmap the tree is read-only (mmap-backed);
+// caller must call Close on the tree's block store when done (via ClosePersisted). LoadPread is
+// like LoadMmap but reads blocks with pread into a bounded cache (see store.OpenPread).
+// With LoadHeap or LoadOffheap, blocks are copied into a new Pool, the file is closed, and the tree accepts Add.
+//
+// An encrypted file (see WithEncryptionKey) needs WithDecryptionKey and cannot be mapped: it is
+// decrypted into an anonymous mapping, which LoadMmap and LoadPread use in place and LoadHeap and
+// LoadOffheap copy from and then wipe.
+func (t *Tree) LoadFrom(path string, opts ...LoadOption) error {
+	if key := applyLoadOptions(opts).key; key != nil {
+		blockStore, err := store.OpenEncrypted(path, key)
+		if err != nil {
+			return err
+		}
+		return t.loadMapped(blockStore)
+	}
+	if t.cfg.OrDefault().LoadMode == LoadPread {
+		blockStore, err := store.OpenPread(path, t.cfg.BlockCacheBlocks)
+		if err != nil {
+			return err
+		}
+		h, treeBuf, routingOffsets, err := readSections(blockStore, blockStore.Size())
+		if err != nil {
+			blockStore.Close()
+			return err
+		}
+		meta, err := store.ReadMetadataAt(blockStore, h, blockStore.Size())
+		if err != nil {
+			blockStore.Close()
+			return err
+		}
+		return t.load(h, treeBuf, routingOffsets, meta, blockStore)
+	}
+	blockStore, err := store.OpenMmap(path)
+	if err != nil {
+		return err
 	}
-	treeBuf := data[treeStart:treeEnd]
+	return t.loadMapped(blockStore)
+}
 
-	routingStart := int64(h.RoutingOffset)
-	routingEnd := routingStart + int64(h.NumBlocks*8)
-	if int64(len(data)) < routingEnd {
+// loadMapped loads from a store holding the whole file in memory, taking ownership of it.
+func (t *Tree) loadMapped(blockStore store.BlockStore) error {
+	data := blockStore.Bytes()
+	h, treeBuf, routingOffsets, err := indexSections(data)
+	if err != nil {
 		blockStore.Close()
-		return nil
+		return err
 	}
-	routingBuf := data[routingStart:routingEnd]
-
-	// Ensure we have block data in the file
-	dataEnd := int64(h.DataOffset) + int64(h.NumBlocks)*int64(store.BlockSizeBytes)
-	if int64(len(data)) < dataEnd {
+	meta, err := store.ReadMetadataAt(bytes.NewReader(data), h, int64(len(data)))
+	if err != nil {
 		blockStore.Close()
-		return errors.New("index file truncated")
+		return err
 	}
+	return t.load(h, treeBuf, routingOffsets, meta, blockStore)
+}
 
-	routingOffsets := make([]int64, h.NumBlocks)
-	r := bytes.NewReader(routingBuf)
-	for i := uint32(0); i < h.NumBlocks; i++ {
-		var off uint64
-		if err := binary.Read(r, binary.LittleEndian, &off); err != nil {
-			blockStore.Close()
+// LoadFromReaderAt loads a tree from an index of size bytes read through r, e.g. a tarball member,
+// an embed.FS file or a blob storage reader. With the default LoadMmap blocks are read on demand
+// through a store.ReaderAtStore and r must stay readable until ClosePersisted; with LoadHeap or
+// LoadOffheap all blocks are copied up front and r is no longer needed. r is never closed.
+// With WithDecryptionKey the whole index is decrypted up front as in LoadFrom.
+func (t *Tree) LoadFromReaderAt(r io.ReaderAt, size int64, opts ...LoadOption) error {
+	if key := applyLoadOptions(opts).key; key != nil {
+		blockStore, err := store.Decrypt(r, size, key)
+		if err != nil {
 			return err
 		}
-		routingOffsets[i] = int64(off)
+		return t.loadMapped(blockStore)
+	}
+	h, treeBuf, routingOffsets, err := readSections(r, size)
+	if err != nil {
+		return err
+	}
+	meta, err := store.ReadMetadataAt(r, h, size)
+	if err != nil {
+		return err
 	}
+	return t.load(h, treeBuf, routingOffsets, meta, store.NewReaderAtStore(r, size, t.cfg.OrDefault().BlockCacheBlocks))
+}
 
-	cfg := t.cfg.OrDefault()
-	cfg.VectorsPerBlock = int(h.VectorsPerBlock)
-	if cfg.VectorsPerBlock <= 0 {
-		cfg.VectorsPerBlock = 64
+// load builds the tree from parsed sections whose blocks are in blockStore, taking ownership of it.
+// The tree's config becomes a copy of its current config merged with the file's (see ConfigMergePolicy).
+func (t *Tree) load(h *store.Header, treeBuf []byte, routingOffsets []int64, meta store.Metadata, blockStore store.BlockStore) error {
+	cfg := mergeFileConfig(t.cfg, h, meta)
+	if err := checkLoadedModel(cfg, meta); err != nil {
+		blockStore.Close()
+		return err
 	}
 
-	root, err := parseTreeStructure(treeBuf, cfg, blockStore, routingOffsets)
+	// Read-only loads use centroids and IDs in place; heap and off-heap loads close blockStore and
+	// update centroids on Add, so they copy.
+	zeroCopy := cfg.LoadMode != LoadHeap && cfg.LoadMode != LoadOffheap
+	root, err := parseTreeStructure(treeBuf, h.Version, cfg, blockStore, routingOffsets, zeroCopy)
 	if err != nil {
 		blockStore.Close()
 		return err
 	}
 
+	// A tree loaded before holds the previous file's store; nothing references it once the new
+	// root is in place.
+	prev := t.persistedStore
+	if cfg.LoadMode == LoadHeap || cfg.LoadMode == LoadOffheap {
+		pool := NewPool(cfg.VectorsPerBlock)
+		pool.UseOffheap = cfg.LoadMode == LoadOffheap
+		copyBlocksToPool(root, pool)
+		if err := blockStore.Close(); err != nil {
+			pool.Close()
+			return err
+		}
+		t.setPool(pool)
+		t.persistedStore = nil
+	} else {
+		t.setPool(nil)
+		t.persistedStore = blockStore
+	}
+
+	t.cfg = cfg
+	t.meta = meta
 	np := new(Node)
 	*np = root
 	t.root.Store(np)
-	t.persistedStore = blockStore
+	if prev != nil {
+		return prev.Close()
+	}
 	return nil
 }
 
-// ClosePersisted releases the search pool (if any) and mmap for a tree loaded via LoadFrom. No-op if not loaded from file.
+// BlockCacheStats returns the block cache counters of a tree loaded with LoadPread or
+// LoadFromReaderAt. ok is false when blocks are not read through a cache.
+func (t *Tree) BlockCacheStats() (stats store.CacheStats, ok bool) {
+	if s, isCached := t.persistedStore.(*store.ReaderAtStore); isCached {
+		return s.Stats(), true
+	}
+	return store.CacheStats{}, false
+}
+
+// ClosePersisted releases the search pool (if any) and mmap for a tree loaded via LoadFrom or
+// NewTreeWritable. No-op if not loaded from file. A writable tree no longer accepts Add afterwards.
 func (t *Tree) ClosePersisted() error {
 	if t.searchPool != nil {
 		t.searchPool.Close()
 		t.searchPool = nil
 	}
+	if t.pool != nil && t.pool.Store != nil {
+		t.setPool(nil)
+	}
 	if t.persistedStore != nil {
 		err := t.persistedStore.Close()
 		t.persistedStore = nil
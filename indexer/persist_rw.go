@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// NewTreeWritable opens or creates a file-backed tree at path whose blocks are allocated directly
+// in a read-write mapping of the file, extended in page-aligned chunks (see store.MmapRWStore).
+// Add writes vectors in place; Sync makes them durable by flushing dirty blocks and appending a
+// small tree structure section, so saving never rewrites the whole file. A file written by SaveTo
+// can be reopened this way. Call Sync before ClosePersisted; adds after the last Sync are lost.
+func NewTreeWritable(path string, cfg *Config) (*Tree, error) {
+	var h *store.Header
+	var meta store.Metadata
+	if st, err := os.Stat(path); err == nil && st.Size() > 0 {
+		h, err = store.ReadHeader(path)
+		if err != nil {
+			return nil, err
+		}
+		meta, err = store.ReadMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg = mergeFileConfig(cfg, h, meta)
+	if err := checkLoadedModel(cfg, meta); err != nil {
+		return nil, err
+	}
+	s, err := store.OpenMmapRW(path, int(blockSizeBytes(cfg.VectorsPerBlock)))
+	if err != nil {
+		return nil, err
+	}
+	t := &Tree{cfg: cfg, meta: meta}
+	if h != nil && h.TreeLen > 0 {
+		_, treeBuf, routingOffsets, err := indexSections(s.Bytes())
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		root, err := parseTreeStructure(treeBuf, h.Version, cfg, s, routingOffsets, false)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		np := new(Node)
+		*np = root
+		t.root.Store(np)
+	}
+	pool := NewPool(cfg.VectorsPerBlock)
+	pool.Store = s
+	t.pool = pool
+	t.persistedStore = s
+	if cfg.SearchPoolWorkers > 0 {
+		t.searchPool = newSingleTreeSearchPool(t, cfg.SearchPoolWorkers, 64)
+	}
+	return t, nil
+}
+
+// Sync makes a tree created by NewTreeWritable durable: dirty blocks are flushed (msync), then a
+// new tree structure section is appended and published by rewriting the header. The space of
+// earlier sections is not reused; SaveToAtomic writes a compact copy.
+// Sync runs off a Snapshot, so Add may continue; vectors added after Sync started are not
+// part of the committed tree. The file's metadata is carried over and merged with opts.
+func (t *Tree) Sync(opts ...SaveOption) error {
+	if t.pool == nil || t.pool.Store == nil {
+		return errors.New("tree is not backed by a writable file")
+	}
+	if applySaveOptions(opts).key != nil {
+		return errors.New("a writable file cannot be encrypted; use SaveToAtomic with WithEncryptionKey")
+	}
+	snap := t.Snapshot()
+	var treeBuf bytes.Buffer
+	var blocks []blockRef
+	if snap.root != nil {
+		if err := serializeNode(&treeBuf, snap.root, &blocks); err != nil {
+			return err
+		}
+	}
+	routing := make([]int64, len(blocks))
+	for i, ref := range blocks {
+		mb, ok := ref.block.(*DataBlockMmap)
+		if !ok || mb.store != store.BlockStore(t.pool.Store) {
+			return errors.New("block is not in the writable store")
+		}
+		routing[i] = mb.Offset()
+	}
+	h := &store.Header{
+		Dim:             BlockDim,
+		VectorsPerBlock: uint32(t.cfg.VectorsPerBlock),
+		BlockSizeBytes:  uint32(blockSizeBytes(t.cfg.VectorsPerBlock)),
+	}
+	meta := buildMetadata(snap.meta, t.cfg, opts)
+	metaBytes, err := store.EncodeMetadata(meta)
+	if err != nil {
+		return err
+	}
+	if err := t.pool.Store.Commit(h, treeBuf.Bytes(), routing, metaBytes); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.meta = meta
+	t.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPersist_WritableMmap(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8 // small blocks so the file grows by several chunks
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(600, 11)
+	path := filepath.Join(t.TempDir(), "rw.bin")
+
+	tree, err := NewTreeWritable(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs[:400] {
+		if !tree.Add(v, uint64(i)) {
+			t.Fatalf("Add failed at %d", i)
+		}
+	}
+	if err := tree.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.ClosePersisted(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewTreeFromFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVectors(t, loaded, vecs[:400])
+	loaded.ClosePersisted()
+
+	// Reopen, keep adding into existing partially filled blocks, and sync again.
+	tree, err = NewTreeWritable(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs[400:] {
+		if !tree.Add(v, uint64(400+i)) {
+			t.Fatalf("Add after reopen failed at %d", 400+i)
+		}
+	}
+	if err := tree.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// Unsynced adds must not be visible after reopening.
+	extra := randomVectors(1, 12)[0]
+	tree.Add(extra, 10_000)
+	if err := tree.ClosePersisted(); err != nil {
+		t.Fatal(err)
+	}
+	if tree.Add(extra, 10_001) {
+		t.Error("Add should fail after ClosePersisted")
+	}
+
+	loaded, err = NewTreeFromFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.ClosePersisted()
+	checkVectors(t, loaded, vecs)
+	results := loaded.SearchMultiPath(vecs[450], 1)
+	if len(results) == 0 || results[0].ChunkID != 450 {
+		t.Errorf("expected chunk 450, got %v", results)
+	}
+}
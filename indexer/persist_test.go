@@ -2,6 +2,8 @@ package indexer
 
 import (
 	"bytes"
+	"encoding/binary"
+	"io"
 	"math"
 	"math/rand"
 	"os"
@@ -67,6 +69,41 @@ func TestSearchMultiPathBatch(t *testing.T) {
 	}
 }
 
+// TestSearchMultiPathBatch_Tiles covers batches that are not a multiple of the simd query tile
+// on a split tree whose leaves span several blocks.
+func TestSearchMultiPathBatch_Tiles(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 40
+	vecs := randomVectors(300, 43)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	for _, nq := range []int{1, 4, 6, 9} {
+		queries := make([][]float32, nq)
+		for i := range queries {
+			queries[i] = vecs[i*31]
+		}
+		batch := tree.SearchMultiPathBatch(queries, 10)
+		for i, q := range queries {
+			single := tree.SearchMultiPath(q, 10)
+			if len(batch[i]) != len(single) {
+				t.Fatalf("nq=%d query %d: batch len=%d single len=%d", nq, i, len(batch[i]), len(single))
+			}
+			if batch[i][0].ChunkID != uint64(i*31) {
+				t.Errorf("nq=%d query %d: top hit %d", nq, i, batch[i][0].ChunkID)
+			}
+			for j := range single {
+				if d := math.Abs(batch[i][j].Score - single[j].Score); d > 1e-5 {
+					t.Errorf("nq=%d query %d result[%d]: batch score %g single %g", nq, i, j, batch[i][j].Score, single[j].Score)
+				}
+			}
+		}
+	}
+}
+
 func TestPersist_SerializeDeserializeRoundtrip(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.VectorsPerBlock = 64
@@ -79,14 +116,17 @@ func TestPersist_SerializeDeserializeRoundtrip(t *testing.T) {
 	}
 	var treeBuf bytes.Buffer
 	var blockBuf bytes.Buffer
-	nextBlockID := 0
-	if err := serializeNode(&treeBuf, leaf, &blockBuf, &nextBlockID); err != nil {
+	var blocks []blockRef
+	if err := serializeNode(&treeBuf, leaf, &blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeBlockData(&blockBuf, blocks, cfg.VectorsPerBlock); err != nil {
 		t.Fatal(err)
 	}
 
 	// Build routing (block i at offset 0, 128KB, 256KB, ...)
-	routingOffsets := make([]int64, nextBlockID)
-	for i := 0; i < nextBlockID; i++ {
+	routingOffsets := make([]int64, len(blocks))
+	for i := 0; i < len(blocks); i++ {
 		routingOffsets[i] = int64(i) * int64(store.BlockSizeBytes)
 	}
 	// For deserialize we need a BlockStore - create a temp file with block data
@@ -100,7 +140,7 @@ func TestPersist_SerializeDeserializeRoundtrip(t *testing.T) {
 	}
 	defer blockStore.Close()
 
-	root, err := parseTreeStructure(treeBuf.Bytes(), cfg, blockStore, routingOffsets)
+	root, err := parseTreeStructure(treeBuf.Bytes(), store.FormatVersion, cfg, blockStore, routingOffsets, true)
 	if err != nil {
 		t.Fatalf("parseTreeStructure: %v", err)
 	}
@@ -220,6 +260,39 @@ func TestPersist_AppendTo(t *testing.T) {
 	}
 }
 
+func TestPersist_ReloadClosesStore(t *testing.T) {
+	vecs := randomVectors(100, 7)
+	tree := NewTree(DefaultConfig())
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(t.TempDir(), "index.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	tree.Pool().Close()
+
+	loaded, err := NewTreeFromFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.ClosePersisted()
+	for _, mode := range []LoadMode{LoadMmap, LoadHeap} {
+		old := loaded.persistedStore.(*store.MmapBlockStore)
+		loaded.cfg.LoadMode = mode
+		if err := loaded.LoadFrom(path); err != nil {
+			t.Fatal(err)
+		}
+		if old.Bytes() != nil {
+			t.Fatalf("mode %d: previous store still mapped after reload", mode)
+		}
+		checkVectors(t, loaded, vecs)
+	}
+	if loaded.persistedStore != nil {
+		t.Fatal("heap reload kept a store")
+	}
+}
+
 func TestPersist_SaveLoad_SearchConsistent(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.VectorsPerBlock = 64
@@ -276,3 +349,284 @@ func TestPersist_SaveLoad_SearchConsistent(t *testing.T) {
 		}
 	})
 }
+
+func TestPersist_LoadHeapWritable(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SplitThreshold = 64
+	vecs := randomVectors(200, 7)
+	tree := NewTree(cfg)
+	for i, v := range vecs[:150] {
+		if !tree.Add(v, uint64(i)) {
+			t.Fatalf("Add failed at %d", i)
+		}
+	}
+	tmp := filepath.Join(t.TempDir(), "heap.bin")
+	if err := tree.SaveToAtomic(tmp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []LoadMode{LoadHeap, LoadOffheap} {
+		loadCfg := *cfg
+		loadCfg.LoadMode = mode
+		tree2, err := NewTreeFromFile(tmp, &loadCfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree2.Pool() == nil {
+			t.Fatalf("mode %d: heap-loaded tree should have a pool", mode)
+		}
+		// The file is no longer needed once blocks are copied.
+		if tree2.persistedStore != nil {
+			t.Errorf("mode %d: heap-loaded tree should not hold the mapping", mode)
+		}
+		before := tree.SearchMultiPath(vecs[3], 5)
+		after := tree2.SearchMultiPath(vecs[3], 5)
+		if len(after) == 0 || after[0].ChunkID != before[0].ChunkID {
+			t.Errorf("mode %d: top result mismatch before=%v after=%v", mode, before, after)
+		}
+		for i, v := range vecs[150:] {
+			if !tree2.Add(v, uint64(150+i)) {
+				t.Fatalf("mode %d: Add after load failed at %d", mode, 150+i)
+			}
+		}
+		results := tree2.SearchMultiPath(vecs[180], 1)
+		if len(results) == 0 || results[0].ChunkID != 180 {
+			t.Errorf("mode %d: expected newly added chunk 180, got %v", mode, results)
+		}
+		tree2.Pool().Close()
+	}
+}
+
+// collectVectors returns every (chunkID, vector) pair stored under n.
+func collectVectors(n Node) map[uint64][]float32 {
+	out := make(map[uint64][]float32)
+	var walk func(n Node)
+	walk = func(n Node) {
+		if n.IsLeaf() {
+			leaf := n.(*LeafNode)
+			for i, id := range leaf.ids {
+				v := make([]float32, BlockDim)
+				leaf.blocks[i/leaf.cfg.VectorsPerBlock].GetVector(i%leaf.cfg.VectorsPerBlock, v)
+				out[id] = v
+			}
+			return
+		}
+		internal := n.(*InternalNode)
+		for i := range internal.children {
+			if child := internal.Child(i); child != nil {
+				walk(child)
+			}
+		}
+	}
+	if n != nil {
+		walk(n)
+	}
+	return out
+}
+
+func checkVectors(t *testing.T, tree *Tree, vecs [][]float32) {
+	t.Helper()
+	root := tree.Root().Load()
+	if root == nil {
+		t.Fatal("tree is empty")
+	}
+	got := collectVectors(*root)
+	if len(got) != len(vecs) {
+		t.Fatalf("vector count: got %d want %d", len(got), len(vecs))
+	}
+	for i, v := range vecs {
+		g, ok := got[uint64(i)]
+		if !ok {
+			t.Fatalf("chunk %d missing", i)
+		}
+		for j := range v {
+			if g[j] != v[j] {
+				t.Fatalf("chunk %d differs at dim %d", i, j)
+			}
+		}
+	}
+}
+
+func TestPersist_WriteToReaderAt(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(300, 41)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+
+	// Embed the index in a larger stream, as in a tarball.
+	var buf bytes.Buffer
+	buf.WriteString("leading member data")
+	start := int64(buf.Len())
+	n, err := tree.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len())-start {
+		t.Fatalf("WriteTo returned %d, wrote %d", n, int64(buf.Len())-start)
+	}
+	path := filepath.Join(t.TempDir(), "idx.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The files match except for the creation time in the metadata section.
+	h, err := store.DecodeHeader(fileData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed := buf.Bytes()[start:]
+	metaEnd := h.MetaOffset + uint64(h.MetaLen)
+	if len(fileData) != len(streamed) || !bytes.Equal(fileData[:h.MetaOffset], streamed[:h.MetaOffset]) ||
+		!bytes.Equal(fileData[metaEnd:], streamed[metaEnd:]) {
+		t.Error("WriteTo output differs from SaveTo file")
+	}
+
+	for _, mode := range []LoadMode{LoadMmap, LoadHeap} {
+		c := *cfg
+		c.LoadMode = mode
+		r := io.NewSectionReader(bytes.NewReader(buf.Bytes()), start, n)
+		loaded, err := NewTreeFromReaderAt(r, n, &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkVectors(t, loaded, vecs)
+		results := loaded.SearchMultiPath(vecs[123], 1)
+		if len(results) == 0 || results[0].ChunkID != 123 {
+			t.Errorf("mode %d: expected chunk 123, got %v", mode, results)
+		}
+		loaded.ClosePersisted()
+		if loaded.Pool() != nil {
+			loaded.Pool().Close()
+		}
+	}
+
+	if _, err := NewTreeFromReaderAt(bytes.NewReader(buf.Bytes()[start:start+100]), 100, cfg); err == nil {
+		t.Error("expected error for truncated index")
+	}
+}
+
+// Header and routing offsets are uint64 on disk; values past the int64 range or near its limit
+// must fail the load instead of wrapping around in the bounds arithmetic.
+func TestPersist_CorruptOffsets(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range randomVectors(40, 61) {
+		tree.Add(v, uint64(i))
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.bin")
+	if err := tree.SaveTo(path, WithMetadata(store.Metadata{"k": "v"})); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h0, err := store.DecodeHeader(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		header  func(h *store.Header)
+		routing uint64 // replaces the first routing entry when non-zero
+	}{
+		{name: "tree offset 1<<63", header: func(h *store.Header) { h.TreeOffset = 1 << 63 }},
+		{name: "tree offset max", header: func(h *store.Header) { h.TreeOffset = math.MaxUint64 }},
+		{name: "tree offset near int64 max", header: func(h *store.Header) { h.TreeOffset = math.MaxInt64 - 8 }},
+		{name: "routing offset 1<<63", header: func(h *store.Header) { h.RoutingOffset = 1 << 63 }},
+		{name: "routing offset near int64 max", header: func(h *store.Header) { h.RoutingOffset = math.MaxInt64 - 8 }},
+		{name: "meta offset 1<<63", header: func(h *store.Header) { h.MetaOffset = 1 << 63 }},
+		{name: "meta offset near int64 max", header: func(h *store.Header) { h.MetaOffset = math.MaxInt64 - 8 }},
+		{name: "block offset 1<<63", routing: 1 << 63},
+		{name: "block offset near int64 max", routing: math.MaxInt64 - 8},
+	} {
+		h := *h0
+		if tc.header != nil {
+			tc.header(&h)
+		}
+		hb, err := store.EncodeHeader(&h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := bytes.Clone(orig)
+		copy(data, hb)
+		if tc.routing != 0 {
+			binary.LittleEndian.PutUint64(data[h0.RoutingOffset:], tc.routing)
+		}
+		bad := filepath.Join(dir, "bad.bin")
+		if err := os.WriteFile(bad, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []LoadMode{LoadMmap, LoadHeap, LoadPread} {
+			c := *cfg
+			c.LoadMode = mode
+			if loaded, err := NewTreeFromFile(bad, &c); err == nil {
+				loaded.ClosePersisted()
+				t.Errorf("%s, mode %d: loaded", tc.name, mode)
+			}
+		}
+		if _, err := NewTreeFromReaderAt(bytes.NewReader(data), int64(len(data)), cfg); err == nil {
+			t.Errorf("%s: loaded from reader", tc.name)
+		}
+	}
+}
+
+func TestPersist_LoadPreadCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(400, 51)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(t.TempDir(), "pread.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	c := *cfg
+	c.LoadMode = LoadPread
+	c.BlockCacheBlocks = 4
+	loaded, err := NewTreeFromFile(path, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.ClosePersisted()
+	checkVectors(t, loaded, vecs)
+	for _, i := range []int{7, 7, 199, 388} {
+		results := loaded.SearchMultiPath(vecs[i], 1)
+		if len(results) == 0 || results[0].ChunkID != uint64(i) {
+			t.Errorf("expected chunk %d, got %v", i, results)
+		}
+	}
+	batch := loaded.SearchMultiPathBatch([][]float32{vecs[3], vecs[250]}, 1)
+	if len(batch) != 2 || len(batch[0]) == 0 || batch[0][0].ChunkID != 3 || batch[1][0].ChunkID != 250 {
+		t.Errorf("batch search: got %v", batch)
+	}
+	stats, ok := loaded.BlockCacheStats()
+	if !ok {
+		t.Fatal("expected block cache stats")
+	}
+	if stats.Resident > stats.Capacity || stats.Capacity != 4 {
+		t.Errorf("resident %d exceeds capacity %d", stats.Resident, stats.Capacity)
+	}
+	if stats.Misses == 0 || stats.Evictions == 0 || stats.Hits == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, ok := tree.BlockCacheStats(); ok {
+		t.Error("heap tree should not report cache stats")
+	}
+}
@@ -3,14 +3,18 @@ package indexer
 import (
 	"runtime"
 	"sync"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
 )
 
-// Pool is a memory pool that pre-allocates Blocks (heap or off-heap).
+// Pool is a memory pool that pre-allocates Blocks (heap, off-heap, or a writable file mapping).
 type Pool struct {
 	mu              sync.Mutex
 	blocks          []Block
 	vectorsPerBlock int
-	UseOffheap      bool // when true and CGO available, use C.malloc
+	UseOffheap      bool                     // when true and CGO available, use C.malloc
+	Store           store.WritableBlockStore // when non-nil, blocks are allocated in the store (takes precedence over UseOffheap)
+	freeOffsets     []int64                  // store blocks returned by FreeBlocks, reused before the store grows
 }
 
 // NewPool creates a memory pool. vectorsPerBlock determines vectors per block.
@@ -26,11 +30,30 @@ func NewPool(vectorsPerBlock int) *Pool {
 	return p
 }
 
-// AllocBlock allocates a new Block. Uses off-heap when UseOffheap is true (requires CGO).
+// AllocBlock allocates a new Block. Uses the writable store when Store is set, off-heap when
+// UseOffheap is true (requires CGO), heap otherwise. Returns nil if the store cannot allocate.
 func (p *Pool) AllocBlock() Block {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	var b Block
+	if p.Store != nil {
+		if n := len(p.freeOffsets); n > 0 {
+			off := p.freeOffsets[n-1]
+			p.freeOffsets = p.freeOffsets[:n-1]
+			mb := NewDataBlockMmap(p.Store, off, p.vectorsPerBlock)
+			clear(mb.Data())
+			p.Store.MarkDirty(off)
+			p.blocks = append(p.blocks, mb)
+			return mb
+		}
+		off, err := p.Store.AllocBlock()
+		if err != nil {
+			return nil
+		}
+		b = NewDataBlockMmap(p.Store, off, p.vectorsPerBlock)
+		p.blocks = append(p.blocks, b)
+		return b
+	}
 	if p.UseOffheap {
 		b = allocBlockOffheap(p.vectorsPerBlock)
 	}
@@ -41,6 +64,28 @@ func (p *Pool) AllocBlock() Block {
 	return b
 }
 
+// FreeBlocks returns blocks from AllocBlock that no leaf references, such as those of a split
+// that failed part-way. Off-heap blocks are released; store blocks are kept for the next
+// AllocBlock, since the file does not shrink.
+func (p *Pool) FreeBlocks(blocks []Block) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, b := range blocks {
+		// Blocks being freed are usually the most recently allocated.
+		for i := len(p.blocks) - 1; i >= 0; i-- {
+			if p.blocks[i] == b {
+				p.blocks = append(p.blocks[:i], p.blocks[i+1:]...)
+				break
+			}
+		}
+		if mb, ok := b.(*DataBlockMmap); ok && p.Store != nil {
+			p.freeOffsets = append(p.freeOffsets, mb.Offset())
+			continue
+		}
+		b.Close()
+	}
+}
+
 // BlockCount returns the number of allocated blocks.
 func (p *Pool) BlockCount() int {
 	p.mu.Lock()
@@ -56,5 +101,6 @@ func (p *Pool) Close() {
 		b.Close()
 	}
 	p.blocks = nil
+	p.freeOffsets = nil
 	runtime.SetFinalizer(p, nil)
 }
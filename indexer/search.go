@@ -1,8 +1,7 @@
 package indexer
 
 import (
-	"slices"
-
+	"github.com/ic-timon/da-hvri/indexer/store"
 	"github.com/ic-timon/da-hvri/simd"
 )
 
@@ -28,13 +27,20 @@ func (t *Tree) SearchMultiPathBatch(queries [][]float32, k int) [][]SearchResult
 	eps := t.cfg.PruneEpsilon
 	candidatesPerLeaf := k * sw
 	bufs := newWorkerBufs()
-	bufs.ensureBatch(len(queries))
+	bufs.resetBatch(len(queries))
 	seenBatch := bufs.seenBatch[:len(queries)]
 	// Step 1: collect leaves per query
 	leafLists := make([][]*LeafNode, len(queries))
 	for i, q := range queries {
 		leafLists[i] = t.collectLeaves(*root, q, candidatesPerLeaf, sw, eps, bufs)
 	}
+	if p, ok := t.persistedStore.(store.Prefetcher); ok {
+		for _, leaves := range leafLists {
+			for _, leaf := range leaves {
+				prefetchLeaf(p, leaf)
+			}
+		}
+	}
 	// Step 2: build leaf -> query indices
 	leafToQueries := make(map[*LeafNode][]int)
 	for i, leaves := range leafLists {
@@ -51,13 +57,13 @@ func (t *Tree) SearchMultiPathBatch(queries [][]float32, k int) [][]SearchResult
 		results := leaf.scanAndTopKBatch(batchQueries, candidatesPerLeaf, bufs)
 		for j, qi := range qIndices {
 			for _, r := range results[j] {
-				(&seenBatch[qi]).upsert(r.ChunkID, r.Score)
+				seenBatch[qi].upsert(r.ChunkID, r.Score)
 			}
 		}
 	}
 	out := make([][]SearchResult, len(queries))
 	for i := range queries {
-		out[i] = topKFromSeen(seenBatch[i], k)
+		out[i] = seenBatch[i].topK(k)
 	}
 	return out
 }
@@ -67,7 +73,7 @@ func (t *Tree) collectLeaves(n Node, query []float32, candidatesPerLeaf int, sea
 		return []*LeafNode{n.(*LeafNode)}
 	}
 	internal := n.(*InternalNode)
-	indices := topKIndicesWithPruning(internal.centroids, query, searchWidth, pruneEpsilon, bufs)
+	indices := topKIndicesWithPruning(internal.centroidMatrix(), query, searchWidth, pruneEpsilon, bufs)
 	var out []*LeafNode
 	for _, idx := range indices {
 		child := internal.Child(idx)
@@ -103,18 +109,18 @@ func (t *Tree) searchMultiPathImpl(query []float32, k int, bufs *workerBufs) []S
 		sw = 3
 	}
 	eps := t.cfg.PruneEpsilon
-	var seen *seenSlice
+	var seen *seenSet
 	if bufs != nil {
 		seen = &bufs.seen
 	} else {
-		s := make(seenSlice, 0, seenBufCap)
+		s := newSeenSet()
 		seen = &s
 	}
 	t.searchMultiPathNode(*root, query, k*sw, sw, eps, seen, bufs)
-	return topKFromSeen(*seen, k)
+	return seen.topK(k)
 }
 
-func (t *Tree) searchMultiPathNode(n Node, query []float32, candidatesPerLeaf int, searchWidth int, pruneEpsilon float64, seen *seenSlice, bufs *workerBufs) {
+func (t *Tree) searchMultiPathNode(n Node, query []float32, candidatesPerLeaf int, searchWidth int, pruneEpsilon float64, seen *seenSet, bufs *workerBufs) {
 	if n.IsLeaf() {
 		leaf := n.(*LeafNode)
 		results := leaf.scanAndTopK(query, candidatesPerLeaf, bufs)
@@ -124,7 +130,14 @@ func (t *Tree) searchMultiPathNode(n Node, query []float32, candidatesPerLeaf in
 		return
 	}
 	internal := n.(*InternalNode)
-	indices := topKIndicesWithPruning(internal.centroids, query, searchWidth, pruneEpsilon, bufs)
+	indices := topKIndicesWithPruning(internal.centroidMatrix(), query, searchWidth, pruneEpsilon, bufs)
+	if p, ok := t.persistedStore.(store.Prefetcher); ok {
+		for _, idx := range indices {
+			if leaf, ok := internal.Child(idx).(*LeafNode); ok {
+				prefetchLeaf(p, leaf)
+			}
+		}
+	}
 	for _, idx := range indices {
 		child := internal.Child(idx)
 		if child != nil {
@@ -133,28 +146,47 @@ func (t *Tree) searchMultiPathNode(n Node, query []float32, candidatesPerLeaf in
 	}
 }
 
+// prefetchLeaf starts reading the blocks of a leaf that is about to be scanned, so disk reads of
+// the selected leaves overlap with scanning the first one.
+func prefetchLeaf(p store.Prefetcher, leaf *LeafNode) {
+	var offsets []int64
+	n := 0
+	for _, b := range leaf.blocks {
+		if mb, ok := b.(*DataBlockMmap); ok {
+			offsets = append(offsets, mb.Offset())
+			n = mb.FloatsPerBlock()
+		}
+	}
+	if len(offsets) > 0 {
+		p.Prefetch(offsets, n)
+	}
+}
+
 // topKIndicesWithPruning 自适应剪枝：仅进入 score >= maxScore - epsilon 的分支，最多 maxK 个
-func topKIndicesWithPruning(centroids [][]float32, query []float32, maxK int, epsilon float64, bufs *workerBufs) []int {
-	if len(centroids) == 0 || maxK <= 0 {
+func topKIndicesWithPruning(centroids []float32, query []float32, maxK int, epsilon float64, bufs *workerBufs) []int {
+	nc := len(centroids) / BlockDim
+	if nc == 0 || maxK <= 0 {
 		return nil
 	}
 	var scores []float64
 	var passed []int
 	if bufs != nil {
-		if cap(bufs.scores) < len(centroids) {
-			bufs.scores = make([]float64, len(centroids))
+		if cap(bufs.scores) < nc {
+			bufs.scores = make([]float64, nc)
 		}
-		scores = bufs.scores[:len(centroids)]
+		scores = bufs.scores[:nc]
 		passed = bufs.indices[:0]
 	} else {
-		scores = make([]float64, len(centroids))
+		scores = make([]float64, nc)
 		passed = make([]int, 0, maxK)
 	}
+	if simd.DotProductBatchFlatInto(query, centroids, nc, scores) == nil {
+		return nil
+	}
 	var dMax float64
-	for i, c := range centroids {
-		scores[i] = simd.DotProduct(query, c)
-		if scores[i] > dMax {
-			dMax = scores[i]
+	for _, s := range scores {
+		if s > dMax {
+			dMax = s
 		}
 	}
 	threshold := dMax - epsilon
@@ -168,42 +200,23 @@ func topKIndicesWithPruning(centroids [][]float32, query []float32, maxK int, ep
 		copy(ret, passed)
 		return ret
 	}
-	// 超过 maxK 个，取 Top-maxK
-	for i := 0; i < maxK; i++ {
-		best := i
-		for j := i + 1; j < len(passed); j++ {
-			if scores[passed[j]] > scores[passed[best]] {
-				best = j
-			}
-		}
-		passed[i], passed[best] = passed[best], passed[i]
-	}
-	ret := make([]int, maxK)
-	copy(ret, passed[:maxK])
-	return ret
-}
-
-// topKFromSeen 从 seenSlice 中取 Top-K
-func topKFromSeen(seen seenSlice, k int) []SearchResult {
-	if len(seen) == 0 || k <= 0 {
-		return nil
+	// 超过 maxK 个，用有界小顶堆取 Top-maxK（按得分降序）
+	var h *topKHeap
+	if bufs != nil {
+		h = &bufs.heap
+	} else {
+		h = new(topKHeap)
 	}
-	slices.SortFunc(seen, func(a, b seenEntry) int {
-		if a.score > b.score {
-			return -1
-		}
-		if a.score < b.score {
-			return 1
+	h.reset(maxK)
+	for _, i := range passed {
+		if h.full() && scores[i] <= h.threshold() {
+			continue
 		}
-		return 0
-	})
-	n := len(seen)
-	if n < k {
-		k = n
+		h.push(i, scores[i])
 	}
-	out := make([]SearchResult, k)
-	for i := 0; i < k; i++ {
-		out[i] = SearchResult{ChunkID: seen[i].id, Score: seen[i].score}
+	ret := make([]int, maxK)
+	for i, it := range h.sorted() {
+		ret[i] = it.idx
 	}
-	return out
+	return ret
 }
@@ -0,0 +1,62 @@
+package indexer
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var benchSearchTree *Tree
+
+func benchTree(b *testing.B) *Tree {
+	if benchSearchTree == nil {
+		tree := NewTree(DefaultConfig())
+		for i, v := range randomVectors(10000, 46) {
+			tree.Add(v, uint64(i))
+		}
+		benchSearchTree = tree
+	}
+	return benchSearchTree
+}
+
+// BenchmarkSearchMultiPath_K runs a worker's search path (leaf scans, candidate dedupe and final
+// top-K) at small and large k.
+func BenchmarkSearchMultiPath_K(b *testing.B) {
+	tree := benchTree(b)
+	queries := randomVectors(64, 47)
+	for _, k := range []int{5, 100, 500} {
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			bufs := newWorkerBufs()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bufs.reset()
+				_ = tree.searchMultiPathImpl(queries[i%len(queries)], k, bufs)
+			}
+		})
+	}
+}
+
+// BenchmarkSeenTopK_K merges k*SearchWidth candidates from each of 9 leaves, half of them already
+// seen, and takes the top k: the dedupe and selection work of one multi-path search.
+func BenchmarkSeenTopK_K(b *testing.B) {
+	for _, k := range []int{5, 100, 500} {
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(48))
+			cands := make([]SearchResult, 9*3*k)
+			for i := range cands {
+				cands[i] = SearchResult{ChunkID: uint64(rng.Intn(len(cands) / 2)), Score: rng.Float64()}
+			}
+			bufs := newWorkerBufs()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bufs.reset()
+				for _, r := range cands {
+					bufs.seen.upsert(r.ChunkID, r.Score)
+				}
+				_ = bufs.seen.topK(k)
+			}
+		})
+	}
+}
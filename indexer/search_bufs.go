@@ -12,23 +12,111 @@ type seenEntry struct {
 	score float64
 }
 
-// seenSlice replaces map[uint64]float64 for cache-friendly linear scan.
-type seenSlice []seenEntry
-
-func (s *seenSlice) upsert(id uint64, score float64) {
-	for i := range *s {
-		if (*s)[i].id == id {
-			if score > (*s)[i].score {
-				(*s)[i].score = score
+// seenLinearMax is the size up to which seenSet finds IDs by linear scan; small sets (k=5 searches)
+// stay a plain slice walk and only larger ones build the hash table.
+const seenLinearMax = 32
+
+// seenSet deduplicates candidate IDs, keeping each one's best score. Entries stay in a dense
+// slice in insertion order; past seenLinearMax entries an open-addressing table (linear probing,
+// power-of-two size, at most half full) maps an ID to its entry, so upsert is O(1).
+type seenSet struct {
+	entries []seenEntry
+	table   []int32 // entry index + 1; 0 marks an empty slot
+	hashed  bool    // table is in use
+	heap    topKHeap
+}
+
+func (s *seenSet) upsert(id uint64, score float64) {
+	if !s.hashed {
+		for i := range s.entries {
+			if s.entries[i].id == id {
+				if score > s.entries[i].score {
+					s.entries[i].score = score
+				}
+				return
+			}
+		}
+		s.entries = append(s.entries, seenEntry{id: id, score: score})
+		if len(s.entries) > seenLinearMax {
+			s.rehash(4 * len(s.entries))
+		}
+		return
+	}
+	mask := len(s.table) - 1
+	for i := seenHash(id) & mask; ; i = (i + 1) & mask {
+		e := s.table[i]
+		if e == 0 {
+			s.entries = append(s.entries, seenEntry{id: id, score: score})
+			s.table[i] = int32(len(s.entries))
+			if 2*len(s.entries) > len(s.table) {
+				s.rehash(2 * len(s.table))
 			}
 			return
 		}
+		if en := &s.entries[e-1]; en.id == id {
+			if score > en.score {
+				en.score = score
+			}
+			return
+		}
+	}
+}
+
+// rehash rebuilds the table with at least size slots from the entries.
+func (s *seenSet) rehash(size int) {
+	n := 64
+	for n < size {
+		n *= 2
+	}
+	if cap(s.table) >= n {
+		s.table = s.table[:n]
+		clear(s.table)
+	} else {
+		s.table = make([]int32, n)
+	}
+	s.hashed = true
+	mask := n - 1
+	for j, en := range s.entries {
+		i := seenHash(en.id) & mask
+		for s.table[i] != 0 {
+			i = (i + 1) & mask
+		}
+		s.table[i] = int32(j + 1)
 	}
-	*s = append(*s, seenEntry{id: id, score: score})
 }
 
-func (s *seenSlice) reset() {
-	*s = (*s)[:0]
+func seenHash(id uint64) int {
+	return int((id * 0x9E3779B97F4A7C15) >> 32)
+}
+
+func newSeenSet() seenSet {
+	return seenSet{entries: make([]seenEntry, 0, seenBufCap)}
+}
+
+// reset empties the set and returns it to linear mode, keeping its buffers.
+func (s *seenSet) reset() {
+	s.entries = s.entries[:0]
+	s.hashed = false
+}
+
+// topK returns the k best entries, best first; ties keep insertion order.
+func (s *seenSet) topK(k int) []SearchResult {
+	if len(s.entries) == 0 || k <= 0 {
+		return nil
+	}
+	s.heap.reset(min(k, len(s.entries)))
+	for i, en := range s.entries {
+		if s.heap.full() && en.score <= s.heap.threshold() {
+			continue
+		}
+		s.heap.push(i, en.score)
+	}
+	items := s.heap.sorted()
+	out := make([]SearchResult, len(items))
+	for i, it := range items {
+		out[i] = SearchResult{ChunkID: s.entries[it.idx].id, Score: it.score}
+	}
+	return out
 }
 
 const maxBatchSize = 16
@@ -37,20 +125,23 @@ const maxBatchSize = 16
 type workerBufs struct {
 	scores       []float64
 	indices      []int
-	seen         seenSlice
-	seenBatch    []seenSlice // for batch mode
-	batchScores  [][]float64 // batchScores[q] for query q in leaf
-	batchIndices [][]int     // batchIndices[q] for query q in leaf
+	heap         topKHeap       // scanAndTopK running top-K
+	results      []SearchResult // scanAndTopK output
+	seen         seenSet
+	seenBatch    []seenSet  // for batch mode
+	batchHeaps   []topKHeap // batchHeaps[q] for query q in leaf
+	batchTile    []float64  // queries × block score tile from simd.DotProductBatchMulti
+	batchResults [][]SearchResult
 }
 
 func newWorkerBufs() *workerBufs {
 	return &workerBufs{
 		scores:       make([]float64, 0, scoresBufCap),
 		indices:      make([]int, 0, indicesBufCap),
-		seen:         make(seenSlice, 0, seenBufCap),
-		seenBatch:    make([]seenSlice, 0, maxBatchSize),
-		batchScores:  make([][]float64, 0, maxBatchSize),
-		batchIndices: make([][]int, 0, maxBatchSize),
+		seen:         newSeenSet(),
+		seenBatch:    make([]seenSet, 0, maxBatchSize),
+		batchHeaps:   make([]topKHeap, 0, maxBatchSize),
+		batchResults: make([][]SearchResult, 0, maxBatchSize),
 	}
 }
 
@@ -60,16 +151,22 @@ func (b *workerBufs) reset() {
 	b.indices = b.indices[:0]
 }
 
+// ensureBatch grows the batch buffers to hold n queries without clearing them.
 func (b *workerBufs) ensureBatch(n int) {
 	for len(b.seenBatch) < n {
-		b.seenBatch = append(b.seenBatch, make(seenSlice, 0, seenBufCap))
+		b.seenBatch = append(b.seenBatch, newSeenSet())
 	}
-	for len(b.batchScores) < n {
-		b.batchScores = append(b.batchScores, make([]float64, 0, scoresBufCap))
+	for len(b.batchHeaps) < n {
+		b.batchHeaps = append(b.batchHeaps, topKHeap{})
 	}
-	for len(b.batchIndices) < n {
-		b.batchIndices = append(b.batchIndices, make([]int, 0, indicesBufCap))
+	for len(b.batchResults) < n {
+		b.batchResults = append(b.batchResults, nil)
 	}
+}
+
+// resetBatch sizes the batch buffers for n queries and clears their seen sets.
+func (b *workerBufs) resetBatch(n int) {
+	b.ensureBatch(n)
 	for i := 0; i < n; i++ {
 		b.seenBatch[i].reset()
 	}
@@ -0,0 +1,45 @@
+package indexer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSeenSet_MatchesMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(49))
+	s := newSeenSet()
+	for _, n := range []int{10, 33, 1000, 5000, 20} { // linear, just past linear, hashed with growth, reuse
+		s.reset()
+		want := make(map[uint64]float64)
+		for i := 0; i < n; i++ {
+			id := uint64(rng.Intn(n/2+1)) << 20 // ids that share low bits
+			score := rng.Float64()
+			s.upsert(id, score)
+			if old, ok := want[id]; !ok || score > old {
+				want[id] = score
+			}
+		}
+		if len(s.entries) != len(want) {
+			t.Fatalf("n=%d: %d entries, want %d", n, len(s.entries), len(want))
+		}
+		for _, en := range s.entries {
+			if want[en.id] != en.score {
+				t.Fatalf("n=%d: id %d score %g, want %g", n, en.id, en.score, want[en.id])
+			}
+		}
+		top := s.topK(7)
+		for i := 1; i < len(top); i++ {
+			if top[i].Score > top[i-1].Score {
+				t.Fatalf("n=%d: topK not sorted: %v", n, top)
+			}
+		}
+		best := 0.0
+		for _, v := range want {
+			best = math.Max(best, v)
+		}
+		if top[0].Score != best {
+			t.Fatalf("n=%d: top score %g, want %g", n, top[0].Score, best)
+		}
+	}
+}
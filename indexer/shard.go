@@ -1,16 +1,20 @@
 package indexer
 
 import (
+	"errors"
+	"fmt"
 	"runtime"
 	"sync"
 )
 
 // ShardedIndex shards vectors across multiple Trees; search queries all shards in parallel and merges results.
 type ShardedIndex struct {
-	shards  []*Tree
-	cfg     *Config
-	nShards int
-	pool    *searchWorkerPool
+	shards     []*Tree
+	cfg        *Config
+	nShards    int
+	pool       *searchWorkerPool
+	model      ModelFingerprint // merged from the shards, which agree (see checkShardModels)
+	queryModel queryModelGuard
 }
 
 // NewShardedIndex creates a sharded index. nShards is the number of shards.
@@ -30,9 +34,107 @@ func NewShardedIndex(cfg *Config, nShards int) *ShardedIndex {
 		cfg:     cfg,
 		nShards: nShards,
 		pool:    newSearchWorkerPool(nWorkers, bufSize),
+		model:   cfg.Model,
 	}
 }
 
+// NewShardedIndexFromTrees assembles a sharded index from existing trees, e.g. built or loaded
+// separately. Each shard's model is checked against cfg.Model (see Config.ModelMismatch), and
+// shards recording different embedding models are refused with ErrModelMismatch. Add routes by
+// chunkID % len(trees), so trees must be in the order the shards were built.
+func NewShardedIndexFromTrees(trees []*Tree, cfg *Config) (*ShardedIndex, error) {
+	if len(trees) == 0 {
+		return nil, errors.New("no shards")
+	}
+	cfg = cfg.OrDefault()
+	for i, sh := range trees {
+		if err := applyModelPolicy(cfg, sh.Model().Check(cfg.Model)); err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	model, err := checkShardModels(trees, func(i int) string { return fmt.Sprint(i) })
+	if err != nil {
+		return nil, err
+	}
+	nWorkers := max(len(trees), runtime.NumCPU()/2)
+	return &ShardedIndex{
+		shards:  append([]*Tree(nil), trees...),
+		cfg:     cfg,
+		nShards: len(trees),
+		pool:    newSearchWorkerPool(nWorkers, 64),
+		model:   mergeModel(model, cfg.Model),
+	}, nil
+}
+
+// checkShardModels returns the merged model of shards, or an error wrapping ErrModelMismatch
+// naming the first shard whose model differs from the earlier ones.
+func checkShardModels(shards []*Tree, name func(i int) string) (ModelFingerprint, error) {
+	var model ModelFingerprint
+	for i, sh := range shards {
+		m := sh.Model()
+		if err := model.Check(m); err != nil {
+			return ModelFingerprint{}, fmt.Errorf("shard %s: %w", name(i), err)
+		}
+		model = mergeModel(model, m)
+	}
+	return model, nil
+}
+
+// NewShardedIndexFromFiles loads one shard per path with NewTreeFromFile. Each shard's model is
+// checked against cfg.Model (see Config.ModelMismatch), and shards recording different embedding
+// models are refused with ErrModelMismatch. Add routes by chunkID % len(paths), so paths must be
+// in the order the shards were built. opts (e.g. WithDecryptionKey) apply to every shard.
+// Call ClosePersisted when done.
+func NewShardedIndexFromFiles(paths []string, cfg *Config, opts ...LoadOption) (*ShardedIndex, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no shard files")
+	}
+	cfg = cfg.OrDefault()
+	shards := make([]*Tree, 0, len(paths))
+	closeAll := func() {
+		for _, sh := range shards {
+			sh.ClosePersisted()
+		}
+	}
+	for _, path := range paths {
+		sh, err := NewTreeFromFile(path, cfg, opts...)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("shard %s: %w", path, err)
+		}
+		shards = append(shards, sh)
+	}
+	model, err := checkShardModels(shards, func(i int) string { return paths[i] })
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	nWorkers := max(len(shards), runtime.NumCPU()/2)
+	return &ShardedIndex{
+		shards:  shards,
+		cfg:     cfg,
+		nShards: len(shards),
+		pool:    newSearchWorkerPool(nWorkers, 64),
+		model:   mergeModel(model, cfg.Model),
+	}, nil
+}
+
+// Model returns the embedding model of the shards' vectors.
+func (s *ShardedIndex) Model() ModelFingerprint {
+	return s.model
+}
+
+// ClosePersisted releases the shards loaded by NewShardedIndexFromFiles.
+func (s *ShardedIndex) ClosePersisted() error {
+	var first error
+	for _, sh := range s.shards {
+		if err := sh.ClosePersisted(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
 // Add inserts a vector, routing to shard by chunkID % nShards.
 func (s *ShardedIndex) Add(vec []float32, chunkID uint64) bool {
 	idx := chunkID % uint64(s.nShards)
@@ -55,20 +157,29 @@ func (s *ShardedIndex) SearchMultiPathBatch(queries [][]float32, k int) [][]Sear
 		}()
 	}
 	wg.Wait()
-	seen := make(seenSlice, 0, seenBufCap)
+	seen := newSeenSet()
 	out := make([][]SearchResult, len(queries))
 	for q := range queries {
-		seen = seen[:0]
+		seen.reset()
 		for sh := 0; sh < s.nShards; sh++ {
 			for _, r := range shardResults[sh][q] {
-				(&seen).upsert(r.ChunkID, r.Score)
+				seen.upsert(r.ChunkID, r.Score)
 			}
 		}
-		out[q] = topKFromSeen(seen, k)
+		out[q] = seen.topK(k)
 	}
 	return out
 }
 
+// SearchMultiPathChecked is SearchMultiPath for queries embedded with model, with the model check
+// of Tree.SearchChecked.
+func (s *ShardedIndex) SearchMultiPathChecked(query []float32, k int, model ModelFingerprint) ([]SearchResult, error) {
+	if err := s.queryModel.check(s.cfg, s.model, model); err != nil {
+		return nil, err
+	}
+	return s.SearchMultiPath(query, k), nil
+}
+
 // SearchMultiPath queries all shards in parallel and merges Top-K results.
 func (s *ShardedIndex) SearchMultiPath(query []float32, k int) []SearchResult {
 	if len(query) != BlockDim || k <= 0 {
@@ -81,11 +192,11 @@ func (s *ShardedIndex) SearchMultiPath(query []float32, k int) []SearchResult {
 		s.pool.Submit(searchJob{i, query, shard, k, results, &wg})
 	}
 	wg.Wait()
-	seen := make(seenSlice, 0, seenBufCap)
+	seen := newSeenSet()
 	for _, rs := range results {
 		for _, r := range rs {
-			(&seen).upsert(r.ChunkID, r.Score)
+			seen.upsert(r.ChunkID, r.Score)
 		}
 	}
-	return topKFromSeen(seen, k)
+	return seen.topK(k)
 }
@@ -0,0 +1,53 @@
+package indexer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewShardedIndexFromTrees_Models(t *testing.T) {
+	build := func(m ModelFingerprint, vecs [][]float32, base int) *Tree {
+		cfg := DefaultConfig()
+		cfg.Model = m
+		tree := NewTree(cfg)
+		for i, v := range vecs {
+			tree.Add(v, uint64(base+i))
+		}
+		return tree
+	}
+	vecs := randomVectors(100, 88)
+	a := ModelFingerprint{Name: "bge-small-zh-v1.5", Dim: 512}
+	b := ModelFingerprint{Name: "text-embedding-3-small", Dim: 1536}
+	shardA := build(a, vecs[:50], 0)
+	shardB := build(b, vecs[50:], 50)
+	shardUnknown := build(ModelFingerprint{}, vecs[50:], 50)
+	for _, sh := range []*Tree{shardA, shardB, shardUnknown} {
+		defer sh.Pool().Close()
+	}
+
+	if _, err := NewShardedIndexFromTrees([]*Tree{shardA, shardB}, nil); !errors.Is(err, ErrModelMismatch) {
+		t.Fatalf("mixed models: got %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Model = b
+	if _, err := NewShardedIndexFromTrees([]*Tree{shardUnknown, shardA}, cfg); !errors.Is(err, ErrModelMismatch) {
+		t.Fatalf("shard model differs from cfg.Model: got %v", err)
+	}
+	if _, err := NewShardedIndexFromTrees(nil, nil); err == nil {
+		t.Fatal("no shards accepted")
+	}
+
+	idx, err := NewShardedIndexFromTrees([]*Tree{shardA, shardUnknown}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Model() != a {
+		t.Fatalf("Model() = %+v, want %+v", idx.Model(), a)
+	}
+	if res, err := idx.SearchMultiPathChecked(vecs[70], 1, a); err != nil || len(res) != 1 || res[0].ChunkID != 70 {
+		t.Fatalf("matching query model: got %v, %v", res, err)
+	}
+	if _, err := idx.SearchMultiPathChecked(vecs[70], 1, b); !errors.Is(err, ErrModelMismatch) {
+		t.Fatalf("mismatching query model: got %v", err)
+	}
+}
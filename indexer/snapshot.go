@@ -0,0 +1,82 @@
+package indexer
+
+import (
+	"sync/atomic"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// Snapshot is an immutable point-in-time view of a Tree. Nodes reachable from a snapshot are
+// never modified: after Snapshot, Add copies a leaf (and the internal nodes on its path) before
+// writing to it, so a snapshot can be saved while the tree keeps accepting Add.
+type Snapshot struct {
+	root  Node // nil for an empty tree
+	cfg   *Config
+	epoch uint64 // nodes changed after the snapshot have epoch >= this
+	meta  store.Metadata
+}
+
+// Snapshot captures the current root. It is O(1); the copy-on-write cost is paid by later Adds,
+// once per node on each path they touch.
+func (t *Tree) Snapshot() *Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.epoch++
+	s := &Snapshot{cfg: t.cfg, epoch: t.epoch, meta: t.meta}
+	if p := t.root.Load(); p != nil {
+		s.root = *p
+	}
+	return s
+}
+
+// writableLeaf returns leaf if it was created in the current epoch; otherwise it stores a copy
+// in slot and returns the copy. Caller holds t.mu and slot's owner is writable.
+func (t *Tree) writableLeaf(slot *atomic.Pointer[Node], leaf *LeafNode) *LeafNode {
+	if leaf.epoch == t.epoch {
+		return leaf
+	}
+	c := *leaf
+	c.centroid = copyVec(leaf.centroid)
+	c.sum = copyVec(leaf.sum)
+	c.epoch = t.epoch
+	np := new(Node)
+	*np = &c
+	slot.Store(np)
+	return &c
+}
+
+// writableInternal is writableLeaf for internal nodes. Child pointers are copied so replacing a
+// child in the copy does not affect snapshots; the published centroid matrix is shared, since a
+// refresh replaces it rather than writing it.
+func (t *Tree) writableInternal(slot *atomic.Pointer[Node], n *InternalNode) *InternalNode {
+	if n.epoch == t.epoch {
+		return n
+	}
+	c := &InternalNode{
+		children: make([]atomic.Pointer[Node], len(n.children)),
+		epoch:    t.epoch,
+		sums:     copyVec(n.sums),
+		counts:   append([]int(nil), n.counts...),
+	}
+	c.centroids.Store(n.centroids.Load())
+	for i := range n.children {
+		c.children[i].Store(n.children[i].Load())
+	}
+	np := new(Node)
+	*np = c
+	slot.Store(np)
+	return c
+}
+
+// stampEpoch marks nodes created by a split as belonging to the current epoch.
+func (t *Tree) stampEpoch(n *InternalNode) {
+	n.epoch = t.epoch
+	for i := range n.children {
+		switch c := n.Child(i).(type) {
+		case *LeafNode:
+			c.epoch = t.epoch
+		case *InternalNode:
+			c.epoch = t.epoch
+		}
+	}
+}
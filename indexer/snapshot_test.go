@@ -0,0 +1,65 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPersist_SnapshotWhileAdding(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(800, 21)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs[:300] {
+		tree.Add(v, uint64(i))
+	}
+
+	snap := tree.Snapshot()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, v := range vecs[300:] {
+			if !tree.Add(v, uint64(300+i)) {
+				t.Errorf("Add failed at %d", 300+i)
+				return
+			}
+		}
+	}()
+	dir := t.TempDir()
+	snapPath := filepath.Join(dir, "snap.bin")
+	if err := snap.SaveToAtomic(snapPath); err != nil {
+		t.Fatal(err)
+	}
+	livePath := filepath.Join(dir, "live.bin")
+	if err := tree.SaveTo(livePath); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	loaded, err := NewTreeFromFile(snapPath, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVectors(t, loaded, vecs[:300])
+	loaded.ClosePersisted()
+
+	// A save taken mid-way holds a prefix of the adds.
+	loaded, err = NewTreeFromFile(livePath, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(collectVectors(*loaded.Root().Load()))
+	if n < 300 {
+		t.Fatalf("live save has %d vectors, want >= 300", n)
+	}
+	checkVectors(t, loaded, vecs[:n])
+	loaded.ClosePersisted()
+
+	// The snapshot is unaffected by later adds, and the tree has everything.
+	if got := len(collectVectors(snap.root)); got != 300 {
+		t.Errorf("snapshot vectors: got %d want 300", got)
+	}
+	checkVectors(t, tree, vecs)
+}
@@ -3,14 +3,19 @@ package indexer
 import (
 	"github.com/ic-timon/da-hvri/simd"
 	"math/rand"
+	"sort"
 )
 
 const (
 	kMeansK      = 2
 	kMeansRounds = 8
+	// minSplitFraction is the smallest share of a leaf's vectors either side of a split may get;
+	// a more lopsided K-means result falls back to medianSplit.
+	minSplitFraction = 0.1
 )
 
-// SplitLeaf splits a full leaf with K-means K=2 and returns the new InternalNode.
+// SplitLeaf splits a full leaf with K-means K=2 and returns the new InternalNode. Both children
+// get at least minSplitFraction of the vectors, so each has room for the retried Add.
 func SplitLeaf(leaf *LeafNode, pool *Pool) *InternalNode {
 	cfg := leaf.cfg.OrDefault()
 	if leaf.VectorCount() < cfg.SplitThreshold {
@@ -31,16 +36,23 @@ func SplitLeaf(leaf *LeafNode, pool *Pool) *InternalNode {
 			idx++
 		}
 	}
-	// K-means K=2，5~10 轮
+	// K-means K=2，5~10 轮；结果过于不均衡（如近似重复向量）时改用中位数切分
 	assign := kMeans2(vecs, kMeansRounds)
+	if !balancedSplit(assign) {
+		assign = medianSplit(vecs)
+	}
 	// 创建 2 个子叶子
 	left := NewLeafNode(pool, cfg)
 	right := NewLeafNode(pool, cfg)
 	for i, a := range assign {
-		if a == 0 {
-			left.Add(pool, vecs[i], ids[i])
-		} else {
-			right.Add(pool, vecs[i], ids[i])
+		dst := left
+		if a != 0 {
+			dst = right
+		}
+		if !dst.Add(pool, vecs[i], ids[i]) {
+			// Block allocation failed: keep the original leaf and return the partial leaves' blocks.
+			pool.FreeBlocks(append(left.blocks, right.blocks...))
+			return nil
 		}
 	}
 	internal := NewInternalNode()
@@ -56,9 +68,12 @@ func kMeans2(vectors [][]float32, rounds int) []int {
 		return make([]int, n)
 	}
 	assign := make([]int, n)
-	// 随机初始化中心
-	c0 := copyVec(vectors[rand.Intn(n)])
-	c1 := copyVec(vectors[rand.Intn(n)])
+	// k-means++ 初始化：c0 随机，c1 按到 c0 的距离平方加权抽取；全部相同则都归簇 0
+	c0, c1 := kMeansPPSeeds(vectors)
+	if c1 == nil {
+		return assign
+	}
+	var members [2][][]float32
 	for r := 0; r < rounds; r++ {
 		// 分配
 		for i, v := range vectors {
@@ -70,38 +85,81 @@ func kMeans2(vectors [][]float32, rounds int) []int {
 				assign[i] = 1
 			}
 		}
-		// 更新中心
-		var sum0, sum1 []float32
-		var cnt0, cnt1 int
+		// 更新中心（空簇保留原中心）
+		members[0], members[1] = members[0][:0], members[1][:0]
 		for i, v := range vectors {
-			if assign[i] == 0 {
-				if sum0 == nil {
-					sum0 = make([]float32, BlockDim)
-				}
-				for j := range v {
-					sum0[j] += v[j]
-				}
-				cnt0++
-			} else {
-				if sum1 == nil {
-					sum1 = make([]float32, BlockDim)
-				}
-				for j := range v {
-					sum1[j] += v[j]
-				}
-				cnt1++
-			}
+			members[assign[i]] = append(members[assign[i]], v)
 		}
-		if cnt0 > 0 {
-			for j := range sum0 {
-				c0[j] = sum0[j] / float32(cnt0)
-			}
+		simd.MeanInto(c0, members[0])
+		simd.MeanInto(c1, members[1])
+	}
+	return assign
+}
+
+// kMeansPPSeeds picks the two initial centers with k-means++: a random vector, then one drawn
+// with probability proportional to its squared distance from the first. c1 is nil if every
+// vector equals c0.
+func kMeansPPSeeds(vectors [][]float32) (c0, c1 []float32) {
+	c0 = copyVec(vectors[rand.Intn(len(vectors))])
+	dist := make([]float64, len(vectors))
+	var total float64
+	for i, v := range vectors {
+		dist[i] = simd.L2Squared(v, c0)
+		total += dist[i]
+	}
+	if !(total > 0) {
+		return c0, nil
+	}
+	r := rand.Float64() * total
+	pick := -1
+	for i, d := range dist {
+		if d > 0 {
+			pick = i
 		}
-		if cnt1 > 0 {
-			for j := range sum1 {
-				c1[j] = sum1[j] / float32(cnt1)
-			}
+		if r -= d; r < 0 && d > 0 {
+			break
+		}
+	}
+	return c0, copyVec(vectors[pick])
+}
+
+// balancedSplit reports whether both clusters of assign hold at least minSplitFraction of it
+// (and at least one vector).
+func balancedSplit(assign []int) bool {
+	ones := 0
+	for _, a := range assign {
+		ones += a
+	}
+	small := ones
+	if n := len(assign) - ones; n < small {
+		small = n
+	}
+	return small > 0 && float64(small) >= minSplitFraction*float64(len(assign))
+}
+
+// medianSplit halves vectors at the median of their projections onto the direction between two
+// k-means++ seeds. Ties are broken randomly, so identical or collinear vectors still split
+// evenly (a random split).
+func medianSplit(vectors [][]float32) []int {
+	n := len(vectors)
+	assign := make([]int, n)
+	if n < 2 {
+		return assign
+	}
+	proj := make([]float64, n)
+	if c0, c1 := kMeansPPSeeds(vectors); c1 != nil {
+		dir := make([]float32, len(c0))
+		for j := range dir {
+			dir[j] = c1[j] - c0[j]
 		}
+		for i, v := range vectors {
+			proj[i] = simd.DotProduct(v, dir)
+		}
+	}
+	order := rand.Perm(n)
+	sort.SliceStable(order, func(a, b int) bool { return proj[order[a]] < proj[order[b]] })
+	for _, i := range order[n/2:] {
+		assign[i] = 1
 	}
 	return assign
 }
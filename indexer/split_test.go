@@ -0,0 +1,105 @@
+package indexer
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+func TestSplitLeaf_Balanced(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	pool := NewPool(cfg.VectorsPerBlock)
+	defer pool.Close()
+	vecs := randomVectors(2, 81)
+	minSide := int(math.Ceil(minSplitFraction * float64(cfg.SplitThreshold)))
+	// All identical, and identical but for one outlier K-means would isolate.
+	for outliers := 0; outliers <= 1; outliers++ {
+		leaf := NewLeafNode(pool, cfg)
+		for i := 0; i < cfg.SplitThreshold; i++ {
+			v := vecs[0]
+			if i < outliers {
+				v = vecs[1]
+			}
+			leaf.Add(pool, v, uint64(i))
+		}
+		internal := SplitLeaf(leaf, pool)
+		if internal == nil {
+			t.Fatalf("%d outliers: split failed", outliers)
+		}
+		for i := 0; i < 2; i++ {
+			if n := internal.Child(i).(*LeafNode).VectorCount(); n < minSide {
+				t.Errorf("%d outliers: child %d has %d vectors, want >= %d", outliers, i, n, minSide)
+			}
+		}
+	}
+}
+
+// limitedStore is an in-memory store.WritableBlockStore whose AllocBlock fails after limit blocks.
+// Offsets are block indexes.
+type limitedStore struct {
+	blocks [][]float32
+	floats int
+	limit  int
+}
+
+func (s *limitedStore) AllocBlock() (int64, error) {
+	if len(s.blocks) >= s.limit {
+		return 0, errors.New("store full")
+	}
+	s.blocks = append(s.blocks, make([]float32, s.floats))
+	return int64(len(s.blocks) - 1), nil
+}
+
+func (s *limitedStore) BlockView(offset int64, n int) []float32 { return s.blocks[offset][:n] }
+func (s *limitedStore) Bytes() []byte                           { return nil }
+func (s *limitedStore) Close() error                            { return nil }
+func (s *limitedStore) MarkDirty(int64)                         {}
+func (s *limitedStore) Commit(*store.Header, []byte, []int64, []byte) error {
+	return nil
+}
+
+func TestSplitLeaf_AllocFailureFreesBlocks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	leafBlocks := cfg.SplitThreshold / cfg.VectorsPerBlock
+	st := &limitedStore{floats: cfg.VectorsPerBlock * BlockDim, limit: leafBlocks + 2}
+	pool := NewPool(cfg.VectorsPerBlock)
+	pool.Store = st
+	defer pool.Close()
+	leaf := NewLeafNode(pool, cfg)
+	for i, v := range randomVectors(cfg.SplitThreshold, 85) {
+		leaf.Add(pool, v, uint64(i))
+	}
+	// Two blocks are too few for both children: the split fails part-way.
+	if SplitLeaf(leaf, pool) != nil {
+		t.Fatal("split succeeded with too few blocks")
+	}
+	if n := pool.BlockCount(); n != leafBlocks {
+		t.Fatalf("pool holds %d blocks after the failed split, want the leaf's %d", n, leafBlocks)
+	}
+	// The returned blocks are reused, zeroed, before the store grows again.
+	st.limit = 100
+	internal := SplitLeaf(leaf, pool)
+	if internal == nil {
+		t.Fatal("split failed")
+	}
+	children := 0
+	for i := 0; i < 2; i++ {
+		children += len(internal.Child(i).(*LeafNode).blocks)
+	}
+	if len(st.blocks) != leafBlocks+children {
+		t.Fatalf("store allocated %d blocks, want %d", len(st.blocks), leafBlocks+children)
+	}
+	got := collectVectors(internal)
+	for i, v := range randomVectors(cfg.SplitThreshold, 85) {
+		if !reflect.DeepEqual(got[uint64(i)], v) {
+			t.Fatalf("chunk %d not stored intact after the split", i)
+		}
+	}
+}
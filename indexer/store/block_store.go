@@ -2,11 +2,25 @@ package store
 
 // BlockStore provides read-only access to persisted blocks.
 type BlockStore interface {
-	// BlockView returns a []float32 view of the block at the given file offset.
+	// BlockView returns a []float32 view of n values starting at the given file offset.
 	// The slice is valid until Close is called. Caller must not modify it.
-	BlockView(offset int64) []float32
+	BlockView(offset int64, n int) []float32
 	// Bytes returns the full mapped file as []byte, or nil if not available.
 	Bytes() []byte
 	// Close releases resources (e.g. unmaps the file).
 	Close() error
 }
+
+// WritableBlockStore is a BlockStore whose blocks can be allocated and modified in place.
+// Views returned by BlockView may be written; call MarkDirty after writing so Commit flushes them.
+type WritableBlockStore interface {
+	BlockStore
+	// AllocBlock reserves a zeroed block and returns its file offset.
+	AllocBlock() (int64, error)
+	// MarkDirty records that the block at offset was modified since the last Commit.
+	MarkDirty(offset int64)
+	// Commit flushes dirty blocks to disk and then atomically replaces the tree structure
+	// section with tree followed by routing and the encoded metadata meta, updating h to
+	// describe the new section.
+	Commit(h *Header, tree []byte, routing []int64, meta []byte) error
+}
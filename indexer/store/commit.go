@@ -0,0 +1,64 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+)
+
+func alignUp(x, align int64) int64 {
+	if x%align == 0 {
+		return x
+	}
+	return (x/align + 1) * align
+}
+
+// CommitTree appends a tree structure section (tree followed by the routing table and the
+// encoded metadata section meta, if any) to f at end, syncs it, and then rewrites the header to
+// point at it. The previous section is left in place, so a crash before the header write still
+// leaves the old tree readable.
+// h is updated to describe the new section. Returns the new end of used file space.
+func CommitTree(f *os.File, end int64, h *Header, tree []byte, routing []int64, meta []byte) (int64, error) {
+	if h == nil {
+		return 0, errors.New("header is nil")
+	}
+	if int64(len(tree)) > math.MaxUint32 || int64(len(routing)) > math.MaxUint32 || int64(len(meta)) > math.MaxUint32 {
+		return 0, errors.New("tree structure section too large")
+	}
+	treeOff := alignUp(end, 8)
+	routingOff := treeOff + int64(len(tree))
+	metaOff := routingOff + 8*int64(len(routing))
+	buf := make([]byte, len(tree)+8*len(routing)+len(meta))
+	copy(buf, tree)
+	for i, off := range routing {
+		binary.LittleEndian.PutUint64(buf[len(tree)+8*i:], uint64(off))
+	}
+	copy(buf[metaOff-treeOff:], meta)
+	if _, err := f.WriteAt(buf, treeOff); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	h.TreeOffset = uint64(treeOff)
+	h.TreeLen = uint32(len(tree))
+	h.RoutingOffset = uint64(routingOff)
+	h.NumBlocks = uint32(len(routing))
+	h.MetaOffset, h.MetaLen = 0, 0
+	if len(meta) > 0 {
+		h.MetaOffset = uint64(metaOff)
+		h.MetaLen = uint32(len(meta))
+	}
+	headerBytes, err := EncodeHeader(h)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.WriteAt(headerBytes, 0); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return treeOff + int64(len(buf)), nil
+}
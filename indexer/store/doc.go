@@ -1,9 +1,21 @@
-// Package store provides the persist file format and mmap-backed block store
-// for the indexer. It is used internally by indexer.SaveTo, indexer.LoadFrom,
-// and indexer.NewTreeFromFile.
+// Package store provides the persist file format and the block stores of the indexer:
+// mmap-backed (MmapBlockStore, MmapRWStore) and io.ReaderAt-backed with a block cache
+// (ReaderAtStore). It is used internally by indexer.SaveTo, indexer.LoadFrom,
+// indexer.LoadFromReaderAt, indexer.NewTreeFromFile and indexer.NewTreeWritable.
 //
 // The file format consists of:
-//   - Header (64 bytes): magic, version, metadata
-//   - Tree structure: serialized node graph
-//   - Block data: contiguous float32 vectors (64 vectors × 512 dim × 4 bytes per block)
+//   - Header (64 bytes): magic, version, block geometry, section offsets
+//   - Tree structure: serialized node graph followed by the routing table (block ID -> file offset).
+//     Since version 3 every node is padded to a multiple of 8 bytes, so a read-only load uses
+//     centroids and chunk IDs in place instead of copying them.
+//   - Metadata (optional): typed key/value entries located by MetaOffset/MetaLen (see Metadata);
+//     ReadMetadata reads it without mapping the file
+//   - Block data: float32 vectors (VectorsPerBlock × 512 dim × 4 bytes per block, page aligned)
+//
+// With a key, SaveTo wraps the whole file in an AES-GCM container (NewEncryptWriter); it is
+// decrypted into an anonymous mapping (OpenEncrypted, Decrypt) since it cannot be mapped directly.
+//
+// SaveTo writes the tree right after the header and all blocks contiguously. MmapRWStore
+// instead grows the file in chunks of blocks and appends a new tree structure section on
+// every Commit, so blocks are located only through the routing table.
 package store
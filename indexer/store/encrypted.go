@@ -0,0 +1,264 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+const (
+	// EncryptedMagic identifies an encrypted index container (see NewEncryptWriter).
+	EncryptedMagic = "DHVE"
+
+	// EncryptedHeaderSize is the size of the encrypted container header.
+	EncryptedHeaderSize = 32
+
+	// EncryptedChunkSize is the plaintext size of every chunk but the last.
+	EncryptedChunkSize = 1 << 20
+
+	encryptedVersion = 1
+	gcmTagSize       = 16
+)
+
+var (
+	// ErrEncrypted is returned when an encrypted index is opened without a key.
+	ErrEncrypted = errors.New("index file is encrypted; a key is required")
+	// ErrNotEncrypted is returned when a key is given for an index that is not encrypted.
+	ErrNotEncrypted = errors.New("index file is not encrypted")
+	// ErrDecrypt is returned when a chunk fails authentication: wrong key, or a corrupted or
+	// truncated file.
+	ErrDecrypt = errors.New("index decryption failed: wrong key or corrupted file")
+)
+
+// The encrypted container wraps a plaintext index file:
+//
+//	header (32 bytes): magic "DHVE", version u16, reserved u16, chunk size u32,
+//	                   nonce prefix [8]byte, reserved [8]byte
+//	chunks:            AES-GCM sealed chunks of chunk size plaintext bytes (the last may be shorter)
+//
+// Chunk i is sealed with nonce = prefix || uint32(i) big endian and additional data = header || last,
+// where last is 1 for the final chunk and 0 otherwise, so chunks cannot be reordered, dropped or
+// moved between files, and truncation at a chunk boundary is detected.
+
+// IsEncrypted reports whether the first bytes of a file are an encrypted container header.
+func IsEncrypted(src []byte) bool {
+	return len(src) >= len(EncryptedMagic) && string(src[:len(EncryptedMagic)]) == EncryptedMagic
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(nonce []byte, prefix []byte, i uint64) []byte {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[8:], uint32(i))
+	return nonce
+}
+
+func chunkAAD(aad []byte, header []byte, last bool) []byte {
+	aad = append(aad[:0], header...)
+	if last {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	out    []byte
+	nonce  []byte
+	aad    []byte
+	chunk  uint64
+	closed bool
+}
+
+// NewEncryptWriter returns a writer that encrypts an index file written to it with AES-GCM under
+// key (16, 24 or 32 bytes for AES-128, -192 or -256). The container header is written on the
+// first Write or Close; Close seals the final chunk and must be called. w is not closed.
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, EncryptedHeaderSize)
+	copy(header, EncryptedMagic)
+	binary.LittleEndian.PutUint16(header[4:], encryptedVersion)
+	binary.LittleEndian.PutUint32(header[8:], EncryptedChunkSize)
+	if _, err := io.ReadFull(rand.Reader, header[12:20]); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, EncryptedChunkSize),
+		nonce:  make([]byte, aead.NonceSize()),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is sealed only once more data arrives, so Close always has a final chunk.
+		if len(e.buf) == EncryptedChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(e.buf[len(e.buf):EncryptedChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	if e.chunk == 0 {
+		if _, err := e.w.Write(e.header); err != nil {
+			return err
+		}
+	}
+	if e.chunk > 1<<32-1 {
+		return errors.New("encrypted index too large")
+	}
+	e.aad = chunkAAD(e.aad, e.header, last)
+	e.out = e.aead.Seal(e.out[:0], chunkNonce(e.nonce, e.header[12:20], e.chunk), e.buf, e.aad)
+	e.chunk++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.out)
+	return err
+}
+
+// Close seals the final chunk. It does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// DecryptedStore is a BlockStore over an index decrypted into an anonymous memory mapping, which
+// is outside the Go heap and wiped on Close. Encrypted files cannot be mapped directly.
+type DecryptedStore struct {
+	data mmap.MMap
+}
+
+// OpenEncrypted decrypts the encrypted index at path with key (see NewEncryptWriter).
+func OpenEncrypted(path string, key []byte) (*DecryptedStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(f, st.Size(), key)
+}
+
+// Decrypt decrypts the encrypted index of size bytes read through r with key. r is not retained.
+func Decrypt(r io.ReaderAt, size int64, key []byte) (*DecryptedStore, error) {
+	header := make([]byte, EncryptedHeaderSize)
+	if _, err := io.ReadFull(io.NewSectionReader(r, 0, size), header); err != nil {
+		return nil, err
+	}
+	if !IsEncrypted(header) {
+		return nil, ErrNotEncrypted
+	}
+	if v := binary.LittleEndian.Uint16(header[4:]); v != encryptedVersion {
+		return nil, errors.New("unsupported encrypted container version")
+	}
+	// The header is authenticated only with the chunks, so bound chunkSize before it sizes the
+	// read buffer: writers use EncryptedChunkSize.
+	chunkSize := int64(binary.LittleEndian.Uint32(header[8:]))
+	if chunkSize == 0 || chunkSize > EncryptedChunkSize {
+		return nil, ErrDecrypt
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every chunk is chunkSize+tag bytes except the last, which holds 1..chunkSize bytes (0 only
+	// for an empty plaintext).
+	body := size - EncryptedHeaderSize
+	sealed := chunkSize + gcmTagSize
+	nChunks := body / sealed
+	plainSize := nChunks * chunkSize
+	if rem := body % sealed; rem != 0 {
+		if rem < gcmTagSize {
+			return nil, ErrDecrypt
+		}
+		nChunks++
+		plainSize += rem - gcmTagSize
+	}
+	if nChunks == 0 || plainSize < HeaderSize {
+		return nil, ErrDecrypt
+	}
+
+	data, err := mmap.MapRegion(nil, int(plainSize), mmap.RDWR, mmap.ANON, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &DecryptedStore{data: data}
+	buf := make([]byte, min(sealed, body)) // a file smaller than one chunk holds only a short final chunk
+	nonce := make([]byte, aead.NonceSize())
+	var aad []byte
+	for i := int64(0); i < nChunks; i++ {
+		off := i * chunkSize
+		n := min(chunkSize, plainSize-off)
+		ct := buf[:n+gcmTagSize]
+		if _, err := io.ReadFull(io.NewSectionReader(r, EncryptedHeaderSize+i*sealed, int64(len(ct))), ct); err != nil {
+			s.Close()
+			return nil, err
+		}
+		aad = chunkAAD(aad, header, i == nChunks-1)
+		if _, err := aead.Open(data[off:off], chunkNonce(nonce, header[12:20], uint64(i)), ct, aad); err != nil {
+			s.Close()
+			return nil, ErrDecrypt
+		}
+	}
+	_ = data.Lock() // best effort: keep the plaintext out of swap; Unmap drops the lock
+	return s, nil
+}
+
+// Bytes returns the decrypted index file.
+func (s *DecryptedStore) Bytes() []byte {
+	return s.data
+}
+
+// BlockView returns a []float32 view of n values at offset.
+// The slice is valid until Close. Caller must not modify it.
+func (s *DecryptedStore) BlockView(offset int64, n int) []float32 {
+	return floatView(s.data, offset, n)
+}
+
+// Close wipes and unmaps the decrypted data.
+func (s *DecryptedStore) Close() error {
+	if s.data == nil {
+		return nil
+	}
+	clear(s.data)
+	err := s.data.Unmap()
+	s.data = nil
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"io"
+	"os"
 )
 
 const (
@@ -14,10 +16,20 @@ const (
 	Magic = "DHVR"
 
 	// FormatVersion is the current file format version.
-	FormatVersion uint16 = 1
+	// Version 2 adds TreeOffset: the tree structure section may live anywhere in the file and
+	// blocks are located only through the routing table.
+	// Version 3 pads tree structure nodes to 8-byte multiples so centroids and IDs can be used in
+	// place from the mapping.
+	FormatVersion uint16 = 3
 
-	// BlockSizeBytes is 64 vectors * 512 dim * 4 bytes = 131072.
+	// MinFormatVersion is the oldest file format version DecodeHeader accepts.
+	MinFormatVersion uint16 = 1
+
+	// BlockSizeBytes is 64 vectors * 512 dim * 4 bytes = 131072 (default VectorsPerBlock).
 	BlockSizeBytes = 64 * 512 * 4
+
+	// PageSize is the alignment used for block data regions.
+	PageSize = 4096
 )
 
 // Header holds the persisted index metadata.
@@ -30,8 +42,19 @@ type Header struct {
 	NumBlocks       uint32
 	TreeLen         uint32
 	RoutingOffset   uint64
-	DataOffset      uint64
-	Reserved        [16]byte // pad to 64 bytes
+	DataOffset      uint64  // start of contiguous block data written by SaveTo; 0 when blocks are scattered
+	TreeOffset      uint64  // start of the tree structure section; 0 means HeaderSize (version 1)
+	MetaOffset      uint64  // start of the metadata section (see EncodeMetadata)
+	MetaLen         uint32  // length of the metadata section; 0 when the file has none
+	Reserved        [4]byte // pad to 64 bytes
+}
+
+// TreeStart returns the file offset of the tree structure section.
+func (h *Header) TreeStart() int64 {
+	if h.Version < 2 || h.TreeOffset == 0 {
+		return HeaderSize
+	}
+	return int64(h.TreeOffset)
 }
 
 // EncodeHeader writes the header to a byte slice, padded to HeaderSize.
@@ -65,10 +88,27 @@ func DecodeHeader(src []byte) (*Header, error) {
 		return nil, err
 	}
 	if string(h.Magic[:]) != Magic {
+		if IsEncrypted(src) {
+			return nil, ErrEncrypted
+		}
 		return nil, errors.New("invalid magic")
 	}
-	if h.Version != FormatVersion {
+	if h.Version < MinFormatVersion || h.Version > FormatVersion {
 		return nil, errors.New("unsupported format version")
 	}
 	return &h, nil
 }
+
+// ReadHeader reads and decodes the header of the index file at path without mapping it.
+func ReadHeader(path string) (*Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return nil, err
+	}
+	return DecodeHeader(buf)
+}
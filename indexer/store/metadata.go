@@ -0,0 +1,221 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Metadata is the typed key/value section of an index file. Values are one of string, int64,
+// uint64, float64, bool, []byte or time.Time; int values are stored as int64.
+type Metadata map[string]any
+
+// Metadata value type tags.
+const (
+	metaString  uint8 = 1
+	metaInt64   uint8 = 2
+	metaUint64  uint8 = 3
+	metaFloat64 uint8 = 4
+	metaBool    uint8 = 5
+	metaBytes   uint8 = 6
+	metaTime    uint8 = 7 // UnixNano, UTC
+)
+
+// String returns the string value of key.
+func (m Metadata) String(key string) (string, bool) {
+	v, ok := m[key].(string)
+	return v, ok
+}
+
+// Int64 returns the int64 value of key.
+func (m Metadata) Int64(key string) (int64, bool) {
+	v, ok := m[key].(int64)
+	return v, ok
+}
+
+// Uint64 returns the uint64 value of key.
+func (m Metadata) Uint64(key string) (uint64, bool) {
+	v, ok := m[key].(uint64)
+	return v, ok
+}
+
+// Float64 returns the float64 value of key.
+func (m Metadata) Float64(key string) (float64, bool) {
+	v, ok := m[key].(float64)
+	return v, ok
+}
+
+// Bool returns the bool value of key.
+func (m Metadata) Bool(key string) (bool, bool) {
+	v, ok := m[key].(bool)
+	return v, ok
+}
+
+// Bytes returns the []byte value of key.
+func (m Metadata) Bytes(key string) ([]byte, bool) {
+	v, ok := m[key].([]byte)
+	return v, ok
+}
+
+// Time returns the time.Time value of key.
+func (m Metadata) Time(key string) (time.Time, bool) {
+	v, ok := m[key].(time.Time)
+	return v, ok
+}
+
+// Clone returns a shallow copy of m.
+func (m Metadata) Clone() Metadata {
+	if m == nil {
+		return nil
+	}
+	out := make(Metadata, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// EncodeMetadata serializes m with keys in sorted order:
+// count u32, then per entry key_len u16, key, type u8, value_len u32, value (little-endian).
+func EncodeMetadata(m Metadata) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if len(k) > math.MaxUint16 {
+			return nil, fmt.Errorf("metadata key too long: %d bytes", len(k))
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(keys)))
+	for _, k := range keys {
+		var typ uint8
+		var val []byte
+		switch v := m[k].(type) {
+		case string:
+			typ, val = metaString, []byte(v)
+		case int:
+			typ, val = metaInt64, binary.LittleEndian.AppendUint64(nil, uint64(v))
+		case int64:
+			typ, val = metaInt64, binary.LittleEndian.AppendUint64(nil, uint64(v))
+		case uint64:
+			typ, val = metaUint64, binary.LittleEndian.AppendUint64(nil, v)
+		case float64:
+			typ, val = metaFloat64, binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+		case bool:
+			typ, val = metaBool, []byte{0}
+			if v {
+				val[0] = 1
+			}
+		case []byte:
+			typ, val = metaBytes, v
+		case time.Time:
+			typ, val = metaTime, binary.LittleEndian.AppendUint64(nil, uint64(v.UnixNano()))
+		default:
+			return nil, fmt.Errorf("metadata %q: unsupported type %T", k, v)
+		}
+		if int64(len(val)) > math.MaxUint32 {
+			return nil, fmt.Errorf("metadata %q: value too large", k)
+		}
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(k)))
+		buf = append(buf, k...)
+		buf = append(buf, typ)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(val)))
+		buf = append(buf, val...)
+	}
+	return buf, nil
+}
+
+var errMetadataCorrupt = errors.New("metadata section corrupt")
+
+// DecodeMetadata parses a section written by EncodeMetadata. Entries of unknown type are skipped.
+func DecodeMetadata(b []byte) (Metadata, error) {
+	if len(b) < 4 {
+		return nil, errMetadataCorrupt
+	}
+	n := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	m := make(Metadata)
+	for i := uint32(0); i < n; i++ {
+		if len(b) < 2 {
+			return nil, errMetadataCorrupt
+		}
+		kl := int(binary.LittleEndian.Uint16(b))
+		if len(b) < 2+kl+5 {
+			return nil, errMetadataCorrupt
+		}
+		key := string(b[2 : 2+kl])
+		typ := b[2+kl]
+		vl := int64(binary.LittleEndian.Uint32(b[3+kl:]))
+		b = b[7+kl:]
+		if int64(len(b)) < vl {
+			return nil, errMetadataCorrupt
+		}
+		val := b[:vl]
+		b = b[vl:]
+		fixed := typ == metaInt64 || typ == metaUint64 || typ == metaFloat64 || typ == metaTime
+		if (fixed && vl != 8) || (typ == metaBool && vl != 1) {
+			return nil, errMetadataCorrupt
+		}
+		switch typ {
+		case metaString:
+			m[key] = string(val)
+		case metaInt64:
+			m[key] = int64(binary.LittleEndian.Uint64(val))
+		case metaUint64:
+			m[key] = binary.LittleEndian.Uint64(val)
+		case metaFloat64:
+			m[key] = math.Float64frombits(binary.LittleEndian.Uint64(val))
+		case metaBool:
+			m[key] = val[0] != 0
+		case metaBytes:
+			m[key] = append([]byte(nil), val...)
+		case metaTime:
+			m[key] = time.Unix(0, int64(binary.LittleEndian.Uint64(val))).UTC()
+		}
+	}
+	return m, nil
+}
+
+// ReadMetadataAt reads the metadata section described by h from r, which holds size bytes.
+// Returns nil, nil when the file has no metadata.
+func ReadMetadataAt(r io.ReaderAt, h *Header, size int64) (Metadata, error) {
+	if h.MetaLen == 0 {
+		return nil, nil
+	}
+	if !inRange(int64(h.MetaOffset), int64(h.MetaLen), size) {
+		return nil, errors.New("index file truncated")
+	}
+	b := make([]byte, h.MetaLen)
+	if n, _ := r.ReadAt(b, int64(h.MetaOffset)); n < len(b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return DecodeMetadata(b)
+}
+
+// ReadMetadata reads the metadata of the index file at path without mapping it or reading blocks.
+// Returns nil, nil when the file has no metadata.
+func ReadMetadata(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return nil, err
+	}
+	h, err := DecodeHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+	return ReadMetadataAt(f, h, st.Size())
+}
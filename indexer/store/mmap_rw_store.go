@@ -0,0 +1,206 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+const (
+	// rwChunkBlocks is the number of blocks added each time an MmapRWStore grows the file.
+	rwChunkBlocks = 64
+
+	// mapAlign is the alignment of mapped chunks (the Windows allocation granularity, a multiple of PageSize).
+	mapAlign = 64 << 10
+)
+
+// segment is one mapped region of the file starting at off.
+type segment struct {
+	off  int64
+	data mmap.MMap
+}
+
+// MmapRWStore is a WritableBlockStore backed by a read-write mapped file.
+// The file grows in page-aligned chunks of blocks; each chunk is mapped separately,
+// so views of existing blocks stay valid while new blocks are allocated.
+type MmapRWStore struct {
+	mu        sync.Mutex
+	f         *os.File
+	blockSize int64
+	segments  atomic.Pointer[[]segment] // sorted by off, replaced on growth
+	chunkNext int64                     // next free block offset in the current chunk
+	chunkEnd  int64                     // end of the current chunk
+	end       int64                     // end of used file space (blocks and tree sections)
+	dirty     map[int64]struct{}
+}
+
+// OpenMmapRW opens or creates path for read-write mapping with blocks of blockSize bytes.
+// An existing file is mapped in full so its blocks stay addressable; new blocks are appended after it.
+func OpenMmapRW(path string, blockSize int) (*MmapRWStore, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("invalid block size")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &MmapRWStore{
+		f:         f,
+		blockSize: int64(blockSize),
+		dirty:     make(map[int64]struct{}),
+	}
+	segs := make([]segment, 0, 1)
+	if size := st.Size(); size > 0 {
+		m, err := mmap.MapRegion(f, int(size), mmap.RDWR, 0, 0)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		segs = append(segs, segment{off: 0, data: m})
+		s.end = size
+	} else {
+		s.end = PageSize // header page, written by the first Commit
+	}
+	s.segments.Store(&segs)
+	return s, nil
+}
+
+// Bytes returns the mapping of the file as it was when opened, or nil for a new file.
+func (s *MmapRWStore) Bytes() []byte {
+	segs := s.segments.Load()
+	if segs == nil || len(*segs) == 0 || (*segs)[0].off != 0 {
+		return nil
+	}
+	return (*segs)[0].data
+}
+
+// BlockView returns a writable []float32 view of n values at offset. The slice is valid until Close.
+func (s *MmapRWStore) BlockView(offset int64, n int) []float32 {
+	seg := s.segmentFor(offset)
+	if seg == nil {
+		return nil
+	}
+	return floatView(seg.data, offset-seg.off, n)
+}
+
+func (s *MmapRWStore) segmentFor(offset int64) *segment {
+	p := s.segments.Load()
+	if p == nil {
+		return nil
+	}
+	segs := *p
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].off > offset }) - 1
+	if i < 0 {
+		return nil
+	}
+	return &segs[i]
+}
+
+// AllocBlock reserves a zeroed block, growing the file by a chunk when needed.
+func (s *MmapRWStore) AllocBlock() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return 0, errors.New("store closed")
+	}
+	if s.chunkNext+s.blockSize > s.chunkEnd {
+		if err := s.grow(); err != nil {
+			return 0, err
+		}
+	}
+	off := s.chunkNext
+	s.chunkNext += s.blockSize
+	return off, nil
+}
+
+// grow extends the file by one chunk after all used space and maps it. Caller holds mu.
+func (s *MmapRWStore) grow() error {
+	off := alignUp(s.end, mapAlign)
+	size := rwChunkBlocks * s.blockSize
+	if err := s.f.Truncate(off + size); err != nil {
+		return err
+	}
+	m, err := mmap.MapRegion(s.f, int(size), mmap.RDWR, 0, off)
+	if err != nil {
+		return err
+	}
+	old := *s.segments.Load()
+	segs := make([]segment, len(old), len(old)+1)
+	copy(segs, old)
+	segs = append(segs, segment{off: off, data: m})
+	s.segments.Store(&segs)
+	s.chunkNext = off
+	s.chunkEnd = off + size
+	s.end = off + size
+	return nil
+}
+
+// MarkDirty records that the block at offset was modified since the last Commit.
+func (s *MmapRWStore) MarkDirty(offset int64) {
+	s.mu.Lock()
+	s.dirty[offset] = struct{}{}
+	s.mu.Unlock()
+}
+
+// Commit flushes dirty blocks (msync) and then appends the new tree structure section and
+// flips the header to it. Blocks referenced by the previous tree are never rewritten in place
+// beyond their committed vectors, so a crash leaves the previously committed tree intact.
+func (s *MmapRWStore) Commit(h *Header, tree []byte, routing []int64, meta []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("store closed")
+	}
+	for off := range s.dirty {
+		seg := s.segmentFor(off)
+		if seg == nil {
+			continue
+		}
+		start := (off - seg.off) &^ (PageSize - 1)
+		end := off - seg.off + s.blockSize
+		if end > int64(len(seg.data)) {
+			end = int64(len(seg.data))
+		}
+		if err := syncRange(s.f, seg.data[start:end]); err != nil {
+			return err
+		}
+	}
+	end, err := CommitTree(s.f, s.end, h, tree, routing, meta)
+	if err != nil {
+		return err
+	}
+	s.end = end
+	s.dirty = make(map[int64]struct{})
+	return nil
+}
+
+// Close unmaps all chunks and closes the file. It does not Commit.
+func (s *MmapRWStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	if p := s.segments.Load(); p != nil {
+		for _, seg := range *p {
+			if err := seg.data.Unmap(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		s.segments.Store(nil)
+	}
+	if s.f != nil {
+		if err := s.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		s.f = nil
+	}
+	return firstErr
+}
@@ -32,17 +32,10 @@ func (s *MmapBlockStore) Bytes() []byte {
 	return s.data
 }
 
-// BlockView returns a []float32 view of the block at offset.
+// BlockView returns a []float32 view of n values at offset.
 // The slice is valid until Close. Caller must not modify it.
-func (s *MmapBlockStore) BlockView(offset int64) []float32 {
-	if s.data == nil {
-		return nil
-	}
-	if offset < 0 || offset+int64(BlockSizeBytes) > int64(len(s.data)) {
-		return nil
-	}
-	ptr := unsafe.Pointer(&s.data[offset])
-	return unsafe.Slice((*float32)(ptr), BlockSizeBytes/4)
+func (s *MmapBlockStore) BlockView(offset int64, n int) []float32 {
+	return floatView(s.data, offset, n)
 }
 
 // Close unmaps the file and closes it.
@@ -60,3 +53,18 @@ func (s *MmapBlockStore) Close() error {
 	}
 	return nil
 }
+
+// floatView returns a []float32 view of n values of data at offset, or nil if out of range.
+func floatView(data []byte, offset int64, n int) []float32 {
+	if data == nil || n <= 0 || !inRange(offset, int64(n)*4, int64(len(data))) {
+		return nil
+	}
+	ptr := unsafe.Pointer(&data[offset])
+	return unsafe.Slice((*float32)(ptr), n)
+}
+
+// inRange reports whether the n bytes at off lie within size bytes. Offsets read from a file may
+// be negative or near the int64 limit, so off is checked before n is added to it.
+func inRange(off, n, size int64) bool {
+	return off >= 0 && n >= 0 && off <= size && n <= size-off
+}
@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package store
+
+import (
+	"errors"
+	"os"
+)
+
+// syncRange is not supported on this platform.
+func syncRange(f *os.File, b []byte) error {
+	return errors.New("msync not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package store
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// syncRange flushes a page-aligned subslice of a shared mapping to disk.
+func syncRange(f *os.File, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return unix.Msync(b, unix.MS_SYNC)
+}
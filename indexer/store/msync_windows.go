@@ -0,0 +1,21 @@
+//go:build windows
+
+package store
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// syncRange flushes a subslice of a mapped view and the file buffers to disk.
+func syncRange(f *os.File, b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	if err := windows.FlushViewOfFile(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b))); err != nil {
+		return os.NewSyscallError("FlushViewOfFile", err)
+	}
+	return f.Sync()
+}
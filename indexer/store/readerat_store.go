@@ -0,0 +1,284 @@
+package store
+
+import (
+	"container/list"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// DefaultCacheBlocks is the number of blocks a ReaderAtStore caches when no size is given.
+const DefaultCacheBlocks = 256
+
+// prefetchWorkers and prefetchQueue bound the background reads started by Prefetch.
+const (
+	prefetchWorkers = 4
+	prefetchQueue   = 256
+)
+
+// Prefetcher is implemented by block stores that can start reading blocks ahead of BlockView.
+type Prefetcher interface {
+	// Prefetch asynchronously loads n values at each offset. It never blocks; requests may be dropped.
+	Prefetch(offsets []int64, n int)
+}
+
+// CacheStats reports block cache activity of a ReaderAtStore.
+type CacheStats struct {
+	Hits       uint64 // BlockView calls served from the cache
+	Misses     uint64 // BlockView calls that read (or waited for a prefetch read of) the block
+	Evictions  uint64 // blocks dropped to stay within capacity
+	Prefetched uint64 // blocks read by Prefetch
+	Resident   int    // blocks currently cached
+	Capacity   int    // maximum cached blocks
+}
+
+// ReaderAtStore is a BlockStore that reads blocks through an io.ReaderAt, for indexes that cannot
+// or should not be mapped: tarball members, embed.FS files, blob storage readers, and files larger
+// than RAM read with pread (OpenPread). Recently used blocks are kept in a bounded LRU cache, so the
+// memory footprint is capacity blocks regardless of the page cache. An evicted block's buffer is
+// never reused, so views returned by BlockView stay valid; they are simply no longer shared.
+type ReaderAtStore struct {
+	r        io.ReaderAt
+	size     int64
+	capacity int
+	closer   io.Closer // non-nil when the store owns r
+
+	mu       sync.Mutex
+	lru      *list.List // of *cachedBlock, most recently used first
+	blocks   map[int64]*list.Element
+	inflight map[int64]chan struct{}
+
+	hits, misses, evictions, prefetched atomic.Uint64
+
+	prefetchOnce sync.Once
+	prefetchCh   chan prefetchReq
+	closed       chan struct{}
+	workers      sync.WaitGroup
+}
+
+type cachedBlock struct {
+	offset int64
+	data   []float32
+}
+
+type prefetchReq struct {
+	offset int64
+	n      int
+}
+
+// NewReaderAtStore returns a store reading from r, which holds size bytes. cacheBlocks <= 0 uses
+// DefaultCacheBlocks. The store does not close r.
+func NewReaderAtStore(r io.ReaderAt, size int64, cacheBlocks int) *ReaderAtStore {
+	if cacheBlocks <= 0 {
+		cacheBlocks = DefaultCacheBlocks
+	}
+	return &ReaderAtStore{
+		r:        r,
+		size:     size,
+		capacity: cacheBlocks,
+		lru:      list.New(),
+		blocks:   make(map[int64]*list.Element),
+		inflight: make(map[int64]chan struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// OpenPread opens path and returns a store that reads blocks with pread (os.File.ReadAt) into a
+// cache of cacheBlocks blocks. Close closes the file.
+func OpenPread(path string, cacheBlocks int) (*ReaderAtStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := NewReaderAtStore(f, st.Size(), cacheBlocks)
+	s.closer = f
+	return s, nil
+}
+
+// BlockView returns n values at offset, reading them from r on a cache miss.
+// Returns nil if the range is outside the file or the read fails.
+func (s *ReaderAtStore) BlockView(offset int64, n int) []float32 {
+	if n <= 0 || !inRange(offset, int64(n)*4, s.size) {
+		return nil
+	}
+	d, hit := s.load(offset, n)
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+	return d
+}
+
+// load returns the cached block at offset, reading it if needed. A concurrent read of the same
+// block (e.g. by Prefetch) is waited for instead of issued twice.
+func (s *ReaderAtStore) load(offset int64, n int) (data []float32, hit bool) {
+	hit = true
+	for {
+		s.mu.Lock()
+		if e, ok := s.blocks[offset]; ok && len(e.Value.(*cachedBlock).data) >= n {
+			s.lru.MoveToFront(e)
+			d := e.Value.(*cachedBlock).data
+			s.mu.Unlock()
+			return d[:n], hit
+		}
+		hit = false
+		if ch, ok := s.inflight[offset]; ok {
+			s.mu.Unlock()
+			<-ch
+			continue
+		}
+		ch := make(chan struct{})
+		s.inflight[offset] = ch
+		s.mu.Unlock()
+
+		d := s.read(offset, n)
+
+		s.mu.Lock()
+		delete(s.inflight, offset)
+		close(ch)
+		if d != nil {
+			s.insert(offset, d)
+		}
+		s.mu.Unlock()
+		return d, false
+	}
+}
+
+func (s *ReaderAtStore) read(offset int64, n int) []float32 {
+	d := make([]float32, n)
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&d[0])), n*4)
+	// ReadAt may return io.EOF together with a full read at the end of the file.
+	if m, _ := s.r.ReadAt(buf, offset); m < len(buf) {
+		return nil
+	}
+	return d
+}
+
+// insert caches d at offset and evicts least recently used blocks. Caller holds s.mu.
+func (s *ReaderAtStore) insert(offset int64, d []float32) {
+	if e, ok := s.blocks[offset]; ok {
+		e.Value.(*cachedBlock).data = d
+		s.lru.MoveToFront(e)
+		return
+	}
+	s.blocks[offset] = s.lru.PushFront(&cachedBlock{offset: offset, data: d})
+	for s.lru.Len() > s.capacity {
+		e := s.lru.Back()
+		s.lru.Remove(e)
+		delete(s.blocks, e.Value.(*cachedBlock).offset)
+		s.evictions.Add(1)
+	}
+}
+
+// Prefetch queues background reads of the blocks at offsets that are not cached or being read.
+// Requests beyond the queue capacity are dropped.
+func (s *ReaderAtStore) Prefetch(offsets []int64, n int) {
+	s.prefetchOnce.Do(s.startPrefetch)
+	for _, off := range offsets {
+		if n <= 0 || !inRange(off, int64(n)*4, s.size) {
+			continue
+		}
+		s.mu.Lock()
+		_, cached := s.blocks[off]
+		_, reading := s.inflight[off]
+		s.mu.Unlock()
+		if cached || reading {
+			continue
+		}
+		select {
+		case <-s.closed:
+			return
+		case s.prefetchCh <- prefetchReq{offset: off, n: n}:
+		default:
+			return
+		}
+	}
+}
+
+func (s *ReaderAtStore) startPrefetch() {
+	s.prefetchCh = make(chan prefetchReq, prefetchQueue)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+	for i := 0; i < prefetchWorkers; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			for {
+				select {
+				case <-s.closed:
+					return
+				case req := <-s.prefetchCh:
+					if _, hit := s.load(req.offset, req.n); !hit {
+						s.prefetched.Add(1)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Stats returns cache counters since the store was created.
+func (s *ReaderAtStore) Stats() CacheStats {
+	s.mu.Lock()
+	resident := s.lru.Len()
+	s.mu.Unlock()
+	return CacheStats{
+		Hits:       s.hits.Load(),
+		Misses:     s.misses.Load(),
+		Evictions:  s.evictions.Load(),
+		Prefetched: s.prefetched.Load(),
+		Resident:   resident,
+		Capacity:   s.capacity,
+	}
+}
+
+// ReadAt reads directly from the underlying reader, bypassing the cache.
+func (s *ReaderAtStore) ReadAt(p []byte, off int64) (int, error) {
+	return s.r.ReadAt(p, off)
+}
+
+// Size returns the size of the underlying file in bytes.
+func (s *ReaderAtStore) Size() int64 {
+	return s.size
+}
+
+// Bytes returns nil; the file is not mapped.
+func (s *ReaderAtStore) Bytes() []byte {
+	return nil
+}
+
+// Close stops prefetching, drops the cache, and closes the file opened by OpenPread.
+// A reader passed to NewReaderAtStore is not closed.
+func (s *ReaderAtStore) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.closed)
+	s.mu.Unlock()
+	s.workers.Wait()
+	s.mu.Lock()
+	s.lru.Init()
+	s.blocks = make(map[int64]*list.Element)
+	s.mu.Unlock()
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package indexer
+
+// scoredIndex is an index into a leaf's vectors (or a node's children) with its score.
+type scoredIndex struct {
+	idx   int
+	score float64
+}
+
+// worse reports whether a ranks below b: lower score, or the same score at a later index.
+func worse(a, b scoredIndex) bool {
+	return a.score < b.score || (a.score == b.score && a.idx > b.idx)
+}
+
+// topKHeap keeps the k best indices pushed so far as a min-heap with the worst at the root, so a
+// candidate is rejected with one comparison once the heap is full. Ties keep the earlier index,
+// the same order a stable descending sort gives.
+type topKHeap struct {
+	k     int
+	items []scoredIndex
+}
+
+func (h *topKHeap) reset(k int) {
+	h.k = k
+	if cap(h.items) < k {
+		h.items = make([]scoredIndex, 0, k)
+	}
+	h.items = h.items[:0]
+}
+
+// full reports whether k items are held; threshold is then the score a candidate must beat.
+func (h *topKHeap) full() bool { return h.k > 0 && len(h.items) >= h.k }
+
+func (h *topKHeap) threshold() float64 { return h.items[0].score }
+
+func (h *topKHeap) push(idx int, score float64) {
+	it := scoredIndex{idx: idx, score: score}
+	if len(h.items) < h.k {
+		h.items = append(h.items, it)
+		h.up(len(h.items) - 1)
+		return
+	}
+	if h.k == 0 || !worse(h.items[0], it) {
+		return
+	}
+	h.items[0] = it
+	h.down(0, len(h.items))
+}
+
+// pushBlock offers the scores of indices base, base+1, ... Scores at or below the threshold of a
+// full heap are dropped without touching it.
+func (h *topKHeap) pushBlock(base int, scores []float64) {
+	for i, s := range scores {
+		if h.full() && s <= h.threshold() {
+			continue
+		}
+		h.push(base+i, s)
+	}
+}
+
+// sorted heap-sorts the items in place, best first, and returns them. push must not be called
+// again before reset.
+func (h *topKHeap) sorted() []scoredIndex {
+	for n := len(h.items) - 1; n > 0; n-- {
+		h.items[0], h.items[n] = h.items[n], h.items[0]
+		h.down(0, n)
+	}
+	return h.items
+}
+
+func (h *topKHeap) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !worse(h.items[i], h.items[p]) {
+			return
+		}
+		h.items[i], h.items[p] = h.items[p], h.items[i]
+		i = p
+	}
+}
+
+func (h *topKHeap) down(i, n int) {
+	for {
+		c := 2*i + 1
+		if c >= n {
+			return
+		}
+		if c+1 < n && worse(h.items[c+1], h.items[c]) {
+			c++
+		}
+		if !worse(h.items[c], h.items[i]) {
+			return
+		}
+		h.items[i], h.items[c] = h.items[c], h.items[i]
+		i = c
+	}
+}
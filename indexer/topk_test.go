@@ -0,0 +1,38 @@
+package indexer
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTopKHeap_MatchesStableSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(45))
+	var h topKHeap
+	for _, n := range []int{1, 7, 64, 300} {
+		scores := make([]float64, n)
+		for i := range scores {
+			scores[i] = float64(rng.Intn(20)) // many ties
+		}
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+		for _, k := range []int{1, 5, n} {
+			h.reset(k)
+			for base := 0; base < n; base += 8 {
+				h.pushBlock(base, scores[base:min(base+8, n)])
+			}
+			got := h.sorted()
+			if len(got) != min(k, n) {
+				t.Fatalf("n=%d k=%d: got %d items", n, k, len(got))
+			}
+			for i, it := range got {
+				if it.idx != order[i] || it.score != scores[order[i]] {
+					t.Fatalf("n=%d k=%d item %d: got (%d, %g), want (%d, %g)", n, k, i, it.idx, it.score, order[i], scores[order[i]])
+				}
+			}
+		}
+	}
+}
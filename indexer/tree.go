@@ -1,33 +1,52 @@
 package indexer
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
 	"sync/atomic"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+	"github.com/ic-timon/da-hvri/simd"
 )
 
 // Tree is a dynamic descending tree supporting single-path search.
 type Tree struct {
 	cfg            *Config
 	pool           *Pool
+	mu             sync.Mutex // serializes Add and Snapshot
+	epoch          uint64     // bumped by Snapshot; nodes from older epochs are copied before writing
+	ckptMu         sync.Mutex // serializes Checkpoint and Compact
+	ckpt           *checkpointState
+	meta           store.Metadata // metadata of the file the tree was loaded from or synced to
 	root           atomic.Pointer[Node]
+	routeScratch   []float32 // BlockDim floats for InternalNode.absorb, under mu
 	searchPool     *singleTreeSearchPool
+	queryModel     queryModelGuard
 	persistedStore interface{ Close() error } // set by LoadFrom, used by ClosePersisted
 }
 
 // NewTree creates a tree. Uses default config if cfg is nil.
-// If cfg.PersistPath is non-empty and the file exists, loads from file (mmap, read-only).
-// Otherwise creates an empty heap tree for Add.
+// If cfg.PersistPath is non-empty and the file exists, loads from file (mmap and read-only by default;
+// see Config.LoadMode). Otherwise creates an empty heap tree for Add.
+//
+// A PersistPath file that exists but fails to load (ErrModelMismatch, store.ErrEncrypted, a
+// corrupt file) panics with an error wrapping the cause rather than coming back as an empty tree
+// that a later save would write over it. Use NewTreeFromFile to handle such errors.
 func NewTree(cfg *Config) *Tree {
 	cfg = cfg.OrDefault()
 	t := &Tree{cfg: cfg}
 	if cfg.PersistPath != "" {
-		if _, err := os.Stat(cfg.PersistPath); err == nil {
-			if err := t.LoadFrom(cfg.PersistPath); err == nil {
-				if cfg.SearchPoolWorkers > 0 {
-					t.searchPool = newSingleTreeSearchPool(t, cfg.SearchPoolWorkers, 64)
-				}
-				return t // mmap tree, pool is nil (read-only)
+		err := t.LoadFrom(cfg.PersistPath)
+		if err == nil {
+			if cfg.SearchPoolWorkers > 0 {
+				t.searchPool = newSingleTreeSearchPool(t, cfg.SearchPoolWorkers, 64)
 			}
+			return t // pool is nil for mmap (read-only), set for heap/off-heap loads
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			panic(fmt.Errorf("indexer: NewTree: load PersistPath: %w", err))
 		}
 	}
 	pool := NewPool(cfg.VectorsPerBlock)
@@ -45,14 +64,22 @@ func (t *Tree) Config() *Config {
 }
 
 // Add inserts a vector. chunkID is the external chunk identifier.
-// Returns false if tree is read-only (loaded via PersistPath/LoadFrom).
+// Returns false if the tree is read-only (loaded with LoadMmap or LoadPread, or a NewTreeWritable
+// tree after ClosePersisted), vec is not BlockDim long, or a block cannot be allocated; duplicate
+// or degenerate input never makes it fail.
 func (t *Tree) Add(vec []float32, chunkID uint64) bool {
-	if t.pool == nil || len(vec) != BlockDim {
+	if len(vec) != BlockDim {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pool == nil {
 		return false
 	}
 	root := t.root.Load()
 	if root == nil {
 		leaf := NewLeafNode(t.pool, t.cfg)
+		leaf.epoch = t.epoch
 		if !leaf.Add(t.pool, vec, chunkID) {
 			return false
 		}
@@ -73,6 +100,7 @@ func (t *Tree) Add(vec []float32, chunkID uint64) bool {
 	if internal == nil {
 		return false
 	}
+	t.stampEpoch(internal)
 	if !t.replaceLeaf(toSplit, internal) {
 		return false
 	}
@@ -84,19 +112,26 @@ func (t *Tree) Add(vec []float32, chunkID uint64) bool {
 	return ok
 }
 
+// addToNode descends to the best leaf, copying nodes shared with a Snapshot on the way. Caller holds t.mu.
 func (t *Tree) addToNode(slot *atomic.Pointer[Node], n Node, vec []float32, chunkID uint64) (ok bool, toSplit *LeafNode) {
 	if n.IsLeaf() {
-		leaf := n.(*LeafNode)
+		leaf := t.markOverflow(slot, n.(*LeafNode))
+		if leaf.overflow {
+			if !sameVector(vec, leaf.vector(0)) {
+				// Only reached when no sibling can take vec (see routeAdd), e.g. at the root.
+				return t.branchOverflow(slot, t.writableLeaf(slot, leaf), vec, chunkID), nil
+			}
+		} else if leaf.VectorCount() >= t.cfg.SplitThreshold {
+			return false, leaf
+		}
+		leaf = t.writableLeaf(slot, leaf)
 		if leaf.Add(t.pool, vec, chunkID) {
 			return true, nil
 		}
-		if leaf.VectorCount() >= t.cfg.SplitThreshold {
-			return false, leaf
-		}
 		return false, nil
 	}
-	internal := n.(*InternalNode)
-	idx := internal.BestChild(vec)
+	internal := t.writableInternal(slot, n.(*InternalNode))
+	idx := t.routeAdd(internal, vec)
 	if idx < 0 {
 		return false, nil
 	}
@@ -104,7 +139,78 @@ func (t *Tree) addToNode(slot *atomic.Pointer[Node], n Node, vec []float32, chun
 	if child == nil {
 		return false, nil
 	}
-	return t.addToNode(internal.ChildSlot(idx), child, vec, chunkID)
+	ok, toSplit = t.addToNode(internal.ChildSlot(idx), child, vec, chunkID)
+	if ok {
+		if t.routeScratch == nil {
+			t.routeScratch = make([]float32, BlockDim)
+		}
+		internal.absorb(idx, vec, t.cfg.routingDrift(), t.routeScratch)
+	}
+	return ok, toSplit
+}
+
+// routeAdd picks the child of internal to add vec under: the best-scoring one, unless that is an
+// overflow leaf vec is not a copy of and a sibling can take vec. Near-duplicates of an overflow
+// leaf's vector thus fill a regular sibling that splits normally, and an overflow leaf is branched
+// (see branchOverflow) at most once. Caller holds t.mu and internal is writable.
+func (t *Tree) routeAdd(internal *InternalNode, vec []float32) int {
+	idx := internal.BestChild(vec)
+	if idx < 0 || !t.rejectsVector(internal, idx, vec) {
+		return idx
+	}
+	m := internal.centroidMatrix()
+	scores := simd.DotProductBatchFlat(vec, m, len(m)/BlockDim)
+	alt := -1
+	for i, s := range scores {
+		if i == idx || (alt >= 0 && s <= scores[alt]) || t.rejectsVector(internal, i, vec) {
+			continue
+		}
+		alt = i
+	}
+	if alt < 0 {
+		return idx
+	}
+	return alt
+}
+
+// rejectsVector reports whether child i of internal is an overflow leaf vec is not a copy of.
+// Caller holds t.mu and internal is writable.
+func (t *Tree) rejectsVector(internal *InternalNode, i int, vec []float32) bool {
+	leaf, ok := internal.Child(i).(*LeafNode)
+	if !ok {
+		return false
+	}
+	leaf = t.markOverflow(internal.ChildSlot(i), leaf)
+	return leaf.overflow && !sameVector(vec, leaf.vector(0))
+}
+
+// markOverflow puts a full leaf of exact duplicates, which no split can separate, in overflow
+// mode so it keeps taking copies past the threshold, and returns the leaf now in slot (a copy if
+// a Snapshot shared it). Caller holds t.mu and slot's owner is writable.
+func (t *Tree) markOverflow(slot *atomic.Pointer[Node], leaf *LeafNode) *LeafNode {
+	if leaf.overflow || leaf.VectorCount() < t.cfg.SplitThreshold || !leaf.duplicatesOnly() {
+		return leaf
+	}
+	leaf = t.writableLeaf(slot, leaf)
+	leaf.overflow = true
+	return leaf
+}
+
+// branchOverflow stores in slot an internal node with the overflow leaf and a new leaf holding
+// vec, which is not a copy of the leaf's vector. Caller holds t.mu and leaf is writable.
+func (t *Tree) branchOverflow(slot *atomic.Pointer[Node], leaf *LeafNode, vec []float32, chunkID uint64) bool {
+	sibling := NewLeafNode(t.pool, leaf.cfg)
+	if !sibling.Add(t.pool, vec, chunkID) {
+		return false
+	}
+	internal := NewInternalNode()
+	internal.AddChild(leaf)
+	internal.AddChild(sibling)
+	t.stampEpoch(internal)
+	np := new(Node)
+	*np = internal
+	slot.Store(np)
+	return true
 }
 
 func (t *Tree) replaceLeaf(old *LeafNode, new *InternalNode) bool {
@@ -167,6 +273,16 @@ func (t *Tree) Root() *atomic.Pointer[Node] {
 	return &t.root
 }
 
+// setPool replaces the tree's pool, releasing the previous one. It waits for an in-flight Add.
+func (t *Tree) setPool(p *Pool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pool != nil && t.pool != p {
+		t.pool.Close()
+	}
+	t.pool = p
+}
+
 // Pool returns the memory pool.
 func (t *Tree) Pool() *Pool {
 	return t.pool
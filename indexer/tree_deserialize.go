@@ -3,13 +3,17 @@ package indexer
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
+	"math"
+	"unsafe"
 
 	"github.com/ic-timon/da-hvri/indexer/store"
 )
 
-// deserializeNode reads a node from r and reconstructs it using blockStore and routingOffsets.
-func deserializeNode(r io.Reader, cfg *Config, blockStore store.BlockStore, routingOffsets []int64) (Node, error) {
+// deserializeNodeV2 reads a node in the format version 1/2 layout (unaligned, no padding) from r
+// and reconstructs it using blockStore and routingOffsets.
+func deserializeNodeV2(r io.Reader, cfg *Config, blockStore store.BlockStore, routingOffsets []int64) (Node, error) {
 	var tag uint8
 	if err := binary.Read(r, binary.LittleEndian, &tag); err != nil {
 		return nil, err
@@ -63,25 +67,152 @@ func deserializeNode(r io.Reader, cfg *Config, blockStore store.BlockStore, rout
 		return nil, err
 	}
 	internal := NewInternalNode()
-	for i := uint16(0); i < nc; i++ {
-		var c [BlockDim]float32
-		if err := binary.Read(r, binary.LittleEndian, &c); err != nil {
-			return nil, err
-		}
-		internal.centroids = append(internal.centroids, copyVec(c[:]))
+	centroids := make([]float32, int(nc)*BlockDim)
+	if err := binary.Read(r, binary.LittleEndian, centroids); err != nil {
+		return nil, err
 	}
 	for i := uint16(0); i < nc; i++ {
-		child, err := deserializeNode(r, cfg, blockStore, routingOffsets)
+		child, err := deserializeNodeV2(r, cfg, blockStore, routingOffsets)
 		if err != nil {
 			return nil, err
 		}
-		internal.AddChild(child)
+		internal.appendChild(child)
 	}
+	internal.setCentroidMatrix(centroids)
 	return internal, nil
 }
 
-// parseTreeStructure reads the tree structure from data and returns the root node.
-func parseTreeStructure(data []byte, cfg *Config, blockStore store.BlockStore, routingOffsets []int64) (Node, error) {
-	r := bytes.NewReader(data)
-	return deserializeNode(r, cfg, blockStore, routingOffsets)
+// parseTreeStructure reads the tree structure from data, written with the given format version,
+// and returns the root node. With zeroCopy, centroids and IDs of a version 3 tree are slices into
+// data instead of copies; data must then stay mapped and unmodified for the life of the tree, and
+// the tree must not update centroids in place. Views are capped at their length, so appending IDs
+// reallocates.
+func parseTreeStructure(data []byte, version uint16, cfg *Config, blockStore store.BlockStore, routingOffsets []int64, zeroCopy bool) (Node, error) {
+	if version < 3 {
+		return deserializeNodeV2(bytes.NewReader(data), cfg, blockStore, routingOffsets)
+	}
+	p := &treeParser{
+		data:           data,
+		cfg:            cfg,
+		blockStore:     blockStore,
+		routingOffsets: routingOffsets,
+		zeroCopy:       zeroCopy && nativeLittleEndian,
+	}
+	return p.node()
+}
+
+var errTreeTruncated = errors.New("tree structure truncated")
+
+// nativeLittleEndian reports whether file data can be viewed in place as native values.
+var nativeLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// treeParser decodes a version 3 tree structure section.
+type treeParser struct {
+	data           []byte
+	pos            int
+	cfg            *Config
+	blockStore     store.BlockStore
+	routingOffsets []int64
+	zeroCopy       bool
+}
+
+func (p *treeParser) next(n int) ([]byte, error) {
+	if n < 0 || len(p.data)-p.pos < n {
+		return nil, errTreeTruncated
+	}
+	b := p.data[p.pos : p.pos+n : p.pos+n]
+	p.pos += n
+	return b, nil
+}
+
+// floats returns n float32 values, in place when zero-copy is possible for b.
+func (p *treeParser) floats(n int) ([]float32, error) {
+	b, err := p.next(n * 4)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	if p.zeroCopy && uintptr(unsafe.Pointer(&b[0]))%4 == 0 {
+		return unsafe.Slice((*float32)(unsafe.Pointer(&b[0])), n), nil
+	}
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return out, nil
+}
+
+// uint64s returns n uint64 values, in place when zero-copy is possible for b.
+func (p *treeParser) uint64s(n int) ([]uint64, error) {
+	b, err := p.next(n * 8)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return make([]uint64, 0), nil
+	}
+	if p.zeroCopy && uintptr(unsafe.Pointer(&b[0]))%8 == 0 {
+		return unsafe.Slice((*uint64)(unsafe.Pointer(&b[0])), n), nil
+	}
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return out, nil
+}
+
+func (p *treeParser) node() (Node, error) {
+	if p.pos >= len(p.data) {
+		return nil, errTreeTruncated
+	}
+	if p.data[p.pos] == nodeTagLeaf {
+		hb, err := p.next(leafHeaderSize)
+		if err != nil {
+			return nil, err
+		}
+		vectorCount := binary.LittleEndian.Uint32(hb[4:])
+		blockCount := binary.LittleEndian.Uint32(hb[8:])
+		firstBlockID := binary.LittleEndian.Uint32(hb[12:])
+		centroid, err := p.floats(BlockDim)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := p.uint64s(int(vectorCount))
+		if err != nil {
+			return nil, err
+		}
+		leaf := &LeafNode{
+			cfg:         p.cfg,
+			blocks:      make([]Block, 0, blockCount),
+			ids:         ids,
+			centroid:    centroid,
+			vectorCount: int(vectorCount),
+		}
+		for i := uint32(0); i < blockCount; i++ {
+			bid := int(firstBlockID) + int(i)
+			if bid >= len(p.routingOffsets) {
+				break
+			}
+			leaf.blocks = append(leaf.blocks, NewDataBlockMmap(p.blockStore, p.routingOffsets[bid], p.cfg.VectorsPerBlock))
+		}
+		return leaf, nil
+	}
+	hb, err := p.next(internalHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	nc := int(binary.LittleEndian.Uint16(hb[2:]))
+	flat, err := p.floats(nc * BlockDim)
+	if err != nil {
+		return nil, err
+	}
+	internal := NewInternalNode()
+	internal.setCentroidMatrix(flat[:len(flat):len(flat)])
+	for i := 0; i < nc; i++ {
+		child, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		internal.appendChild(child)
+	}
+	return internal, nil
 }
@@ -0,0 +1,66 @@
+package indexer
+
+import (
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+func TestPersist_ZeroCopyTree(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	vecs := randomVectors(200, 61)
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(t.TempDir(), "zc.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	inMapping := func(tr *Tree, p unsafe.Pointer) bool {
+		data := tr.persistedStore.(store.BlockStore).Bytes()
+		start := uintptr(unsafe.Pointer(&data[0]))
+		return uintptr(p) >= start && uintptr(p) < start+uintptr(len(data))
+	}
+	firstLeaf := func(tr *Tree) (*InternalNode, *LeafNode) {
+		internal := (*tr.Root().Load()).(*InternalNode)
+		n := internal.Child(0)
+		for !n.IsLeaf() {
+			n = n.(*InternalNode).Child(0)
+		}
+		return internal, n.(*LeafNode)
+	}
+
+	loaded, err := NewTreeFromFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.ClosePersisted()
+	checkVectors(t, loaded, vecs)
+	root, leaf := firstLeaf(loaded)
+	if !inMapping(loaded, unsafe.Pointer(&leaf.ids[0])) || !inMapping(loaded, unsafe.Pointer(&leaf.centroid[0])) ||
+		!inMapping(loaded, unsafe.Pointer(&root.centroid(1)[0])) {
+		t.Error("read-only load should use centroids and IDs in place")
+	}
+
+	// Heap loads outlive the file and update centroids, so they must copy.
+	c := *cfg
+	c.LoadMode = LoadHeap
+	heap, err := NewTreeFromFile(path, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer heap.Pool().Close()
+	checkVectors(t, heap, vecs)
+	for i, v := range randomVectors(50, 62) {
+		if !heap.Add(v, uint64(1000+i)) {
+			t.Fatalf("Add after heap load failed at %d", i)
+		}
+	}
+}
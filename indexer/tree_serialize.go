@@ -1,7 +1,6 @@
 package indexer
 
 import (
-	"bytes"
 	"encoding/binary"
 	"io"
 )
@@ -11,67 +10,101 @@ const (
 	nodeTagLeaf     = 1
 )
 
-// serializeNode writes the node to w in pre-order. Returns the number of blocks written.
-func serializeNode(w io.Writer, n Node, blockData *bytes.Buffer, nextBlockID *int) error {
+// blockRef is a block collected by serializeNode together with the number of vectors the leaf
+// had stored in it. Slots past vectors may be written by a concurrent Add and are not read.
+type blockRef struct {
+	block   Block
+	vectors int
+}
+
+// appendLeafBlocks appends the leaf's blocks with the number of vectors used in each.
+func appendLeafBlocks(leaf *LeafNode, out *[]blockRef) {
+	remaining := leaf.vectorCount
+	for _, b := range leaf.blocks {
+		used := min(remaining, b.VectorsPerBlock())
+		*out = append(*out, blockRef{block: b, vectors: used})
+		remaining -= used
+	}
+}
+
+// leafHeader and internalHeader start each serialized node (format version 3). Every node is a
+// multiple of 8 bytes long, so centroids and IDs stay aligned when the tree section is 8-byte
+// aligned and can be used in place (see parseTreeStructure).
+//
+// Leaf:     leafHeader (16 bytes), centroid [BlockDim]float32, ids [VectorCount]uint64
+// Internal: internalHeader (8 bytes), centroids [NumChildren][BlockDim]float32, then the children
+type leafHeader struct {
+	Tag          uint8
+	_            [3]byte
+	VectorCount  uint32
+	BlockCount   uint32
+	FirstBlockID uint32
+}
+
+type internalHeader struct {
+	Tag         uint8
+	_           uint8
+	NumChildren uint16
+	_           uint32
+}
+
+const (
+	leafHeaderSize     = 16
+	internalHeaderSize = 8
+)
+
+// serializeNode writes the node to w in pre-order and appends each leaf's blocks to blocks.
+// A leaf records the index of its first block in blocks as FirstBlockID.
+func serializeNode(w io.Writer, n Node, blocks *[]blockRef) error {
 	if n.IsLeaf() {
 		leaf := n.(*LeafNode)
-		firstBlockID := *nextBlockID
-		vpb := leaf.cfg.VectorsPerBlock
-		floatsPerBlock := vpb * BlockDim
-		for _, b := range leaf.blocks {
-			d := b.Data()
-			if len(d) >= floatsPerBlock {
-				binary.Write(blockData, binary.LittleEndian, d[:floatsPerBlock])
-			} else {
-				pad := make([]float32, floatsPerBlock)
-				copy(pad, d)
-				binary.Write(blockData, binary.LittleEndian, pad)
-			}
-			*nextBlockID++
+		lh := leafHeader{
+			Tag:          nodeTagLeaf,
+			VectorCount:  uint32(leaf.vectorCount),
+			BlockCount:   uint32(len(leaf.blocks)),
+			FirstBlockID: uint32(len(*blocks)),
 		}
-		blockCount := len(leaf.blocks)
-		// tag
-		if err := binary.Write(w, binary.LittleEndian, uint8(nodeTagLeaf)); err != nil {
+		appendLeafBlocks(leaf, blocks)
+		if err := binary.Write(w, binary.LittleEndian, &lh); err != nil {
 			return err
 		}
-		// centroid
 		if err := binary.Write(w, binary.LittleEndian, leaf.centroid); err != nil {
 			return err
 		}
-		// vector_count, block_count, first_block_id
-		if err := binary.Write(w, binary.LittleEndian, uint32(leaf.vectorCount)); err != nil {
-			return err
-		}
-		if err := binary.Write(w, binary.LittleEndian, uint32(blockCount)); err != nil {
-			return err
-		}
-		if err := binary.Write(w, binary.LittleEndian, uint32(firstBlockID)); err != nil {
-			return err
-		}
-		// ids
-		if err := binary.Write(w, binary.LittleEndian, leaf.ids); err != nil {
-			return err
-		}
-		return nil
+		return binary.Write(w, binary.LittleEndian, leaf.ids[:leaf.vectorCount])
 	}
 	internal := n.(*InternalNode)
-	// tag
-	if err := binary.Write(w, binary.LittleEndian, uint8(nodeTagInternal)); err != nil {
-		return err
-	}
 	nc := len(internal.children)
-	if err := binary.Write(w, binary.LittleEndian, uint16(nc)); err != nil {
+	ih := internalHeader{Tag: nodeTagInternal, NumChildren: uint16(nc)}
+	if err := binary.Write(w, binary.LittleEndian, &ih); err != nil {
 		return err
 	}
-	for i := 0; i < nc; i++ {
-		if err := binary.Write(w, binary.LittleEndian, internal.centroids[i]); err != nil {
-			return err
-		}
+	if err := binary.Write(w, binary.LittleEndian, internal.centroidMatrix()[:nc*BlockDim]); err != nil {
+		return err
 	}
 	for i := 0; i < nc; i++ {
 		child := internal.Child(i)
 		if child != nil {
-			if err := serializeNode(w, child, blockData, nextBlockID); err != nil {
+			if err := serializeNode(w, child, blocks); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeBlockData writes vectorsPerBlock*BlockDim floats per block to w. Only the used vectors
+// of each block are read; the rest of the block is written as zeros.
+func writeBlockData(w io.Writer, blocks []blockRef, vectorsPerBlock int) error {
+	floatsPerBlock := vectorsPerBlock * BlockDim
+	pad := make([]float32, floatsPerBlock)
+	for _, ref := range blocks {
+		n := min(ref.vectors*BlockDim, floatsPerBlock)
+		if err := binary.Write(w, binary.LittleEndian, ref.block.Data()[:n]); err != nil {
+			return err
+		}
+		if n < floatsPerBlock {
+			if err := binary.Write(w, binary.LittleEndian, pad[n:]); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,313 @@
+package indexer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+	"github.com/ic-timon/da-hvri/simd"
+)
+
+// driftingClusters returns n vectors from nClusters clusters arriving one cluster after another,
+// so the first splits see only the first topics, plus held-out queries from random clusters.
+func driftingClusters(nClusters, perCluster, nQueries int, noise float64, seed int64) (vecs, queries [][]float32) {
+	rng := rand.New(rand.NewSource(seed))
+	centers := make([][]float32, nClusters)
+	for i := range centers {
+		centers[i] = make([]float32, BlockDim)
+		for j := range centers[i] {
+			centers[i][j] = float32(rng.NormFloat64())
+		}
+		simd.Normalize(centers[i])
+	}
+	sample := func(c int) []float32 {
+		v := make([]float32, BlockDim)
+		for j := range v {
+			v[j] = centers[c][j] + float32(rng.NormFloat64()*noise)
+		}
+		simd.Normalize(v)
+		return v
+	}
+	for c := 0; c < nClusters; c++ {
+		for i := 0; i < perCluster; i++ {
+			vecs = append(vecs, sample(c))
+		}
+	}
+	for i := 0; i < nQueries; i++ {
+		queries = append(queries, sample(rng.Intn(nClusters)))
+	}
+	return vecs, queries
+}
+
+// recallAt returns the fraction of the exact top-k of each query that search finds.
+func recallAt(k int, vecs, queries [][]float32, search func(q []float32, k int) []SearchResult) float64 {
+	hits := 0
+	scores := make([]float64, len(vecs))
+	order := make([]int, len(vecs))
+	for _, q := range queries {
+		for i, v := range vecs {
+			scores[i] = simd.DotProduct(q, v)
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+		truth := make(map[uint64]bool, k)
+		for _, i := range order[:k] {
+			truth[uint64(i)] = true
+		}
+		for _, r := range search(q, k) {
+			if truth[r.ChunkID] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(k*len(queries))
+}
+
+func TestRoutingCentroids_RecallOnDriftingStream(t *testing.T) {
+	vecs, queries := driftingClusters(30, 400, 150, 0.04, 70)
+	build := func(drift float64) *Tree {
+		cfg := DefaultConfig()
+		cfg.RoutingDrift = drift
+		tree := NewTree(cfg)
+		// Snapshots are serialized during the build so -race covers refreshed rows replacing a
+		// centroid matrix that a snapshot's nodes still share.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 20; i++ {
+				if _, err := tree.Snapshot().WriteTo(io.Discard); err != nil {
+					t.Errorf("snapshot %d: %v", i, err)
+					return
+				}
+			}
+		}()
+		for i, v := range vecs {
+			if !tree.Add(v, uint64(i)) {
+				t.Fatalf("Add failed at %d", i)
+			}
+		}
+		<-done
+		return tree
+	}
+	frozen, fresh := build(-1), build(0)
+	frozenRecall := recallAt(10, vecs, queries, frozen.Search)
+	freshRecall := recallAt(10, vecs, queries, fresh.Search)
+	multiRecall := recallAt(10, vecs, queries, fresh.SearchMultiPath)
+	t.Logf("Search recall@10: split-time centroids %.3f, refreshed %.3f; SearchMultiPath %.3f", frozenRecall, freshRecall, multiRecall)
+	if freshRecall < 0.7 || freshRecall < frozenRecall+0.25 {
+		t.Errorf("Search recall@10 with refreshed centroids = %.3f, split-time centroids = %.3f", freshRecall, frozenRecall)
+	}
+	// Splits are randomized (k-means++, tie-breaking); builds range about 0.94-0.97.
+	if multiRecall < 0.9 {
+		t.Errorf("SearchMultiPath recall@10 = %.3f, want >= 0.9", multiRecall)
+	}
+
+	// Every routing row is within the drift threshold of its subtree's mean.
+	var check func(n Node) ([]float64, int)
+	check = func(n Node) ([]float64, int) {
+		sum := make([]float64, BlockDim)
+		if leaf, ok := n.(*LeafNode); ok {
+			for i, x := range leaf.centroid {
+				sum[i] = float64(x) * float64(leaf.vectorCount)
+			}
+			return sum, leaf.vectorCount
+		}
+		internal := n.(*InternalNode)
+		total := 0
+		for i := range internal.children {
+			s, cnt := check(internal.Child(i))
+			var d2 float64
+			for j, x := range internal.centroid(i) {
+				d := float64(x) - s[j]/float64(cnt)
+				d2 += d * d
+				sum[j] += s[j]
+			}
+			if d := math.Sqrt(d2); d > defaultRoutingDrift*1.01 {
+				t.Errorf("routing row %d is %.4f from its subtree mean (%d vectors)", i, d, cnt)
+			}
+			total += cnt
+		}
+		return sum, total
+	}
+	if _, n := check(*fresh.Root().Load()); n != len(vecs) {
+		t.Errorf("tree holds %d vectors, want %d", n, len(vecs))
+	}
+}
+
+func TestTree_AddDuplicates(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	distinct := randomVectors(200, 82)
+	dup := distinct[0]
+	near := copyVec(dup)
+	near[0] = math.Nextafter32(near[0], 1)
+	// Exact duplicates, near duplicates one ulp away, then distinct vectors and more duplicates.
+	var vecs [][]float32
+	for i := 0; i < 300; i++ {
+		vecs = append(vecs, dup)
+	}
+	for i := 0; i < 100; i++ {
+		vecs = append(vecs, near, dup)
+	}
+	vecs = append(vecs, distinct[1:]...)
+	for i := 0; i < 100; i++ {
+		vecs = append(vecs, dup)
+	}
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		if !tree.Add(v, uint64(i)) {
+			t.Fatalf("Add failed at %d", i)
+		}
+	}
+	checkVectors(t, tree, vecs)
+	var walk func(n Node)
+	walk = func(n Node) {
+		if leaf, ok := n.(*LeafNode); ok {
+			if leaf.VectorCount() > cfg.SplitThreshold && !(leaf.overflow && leaf.duplicatesOnly()) {
+				t.Errorf("leaf holds %d vectors but is not an overflow leaf of duplicates", leaf.VectorCount())
+			}
+			return
+		}
+		internal := n.(*InternalNode)
+		for i := range internal.children {
+			walk(internal.Child(i))
+		}
+	}
+	walk(*tree.Root().Load())
+	for i := 500; i < 600; i += 10 {
+		if r := tree.SearchMultiPath(vecs[i], 1); len(r) == 0 || r[0].ChunkID != uint64(i) {
+			t.Errorf("SearchMultiPath(vecs[%d]) = %v", i, r)
+		}
+	}
+
+	// An overflow leaf survives a save and keeps taking duplicates after a writable load.
+	path := filepath.Join(t.TempDir(), "dup.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	loadCfg := *cfg
+	loadCfg.LoadMode = LoadHeap
+	loaded, err := NewTreeFromFile(path, &loadCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Pool().Close()
+	for i := 0; i < 100; i++ {
+		v := dup
+		if i%10 == 0 {
+			v = distinct[i/10+1]
+		}
+		vecs = append(vecs, v)
+		if !loaded.Add(v, uint64(len(vecs)-1)) {
+			t.Fatalf("Add after load failed at %d", len(vecs)-1)
+		}
+	}
+	checkVectors(t, loaded, vecs)
+}
+
+// treeDepth returns the number of nodes on the longest root-to-leaf path under n.
+func treeDepth(n Node) int {
+	internal, ok := n.(*InternalNode)
+	if !ok {
+		return 1
+	}
+	d := 0
+	for i := range internal.children {
+		d = max(d, treeDepth(internal.Child(i)))
+	}
+	return d + 1
+}
+
+// Near-duplicates streamed after a leaf overflowed go to a regular sibling that splits normally,
+// rather than each wrapping the overflow leaf one level deeper.
+func TestTree_NearDuplicatesAfterOverflow(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.VectorsPerBlock = 8
+	cfg.SplitThreshold = 32
+	base := randomVectors(1, 83)[0]
+	rng := rand.New(rand.NewSource(84))
+	var vecs [][]float32
+	for i := 0; i < 40; i++ {
+		vecs = append(vecs, base)
+	}
+	for i := 0; i < 2000; i++ {
+		v := copyVec(base)
+		for j := range v {
+			v[j] += float32(rng.NormFloat64() * 1e-3)
+		}
+		simd.Normalize(v)
+		vecs = append(vecs, v)
+	}
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range vecs {
+		if !tree.Add(v, uint64(i)) {
+			t.Fatalf("Add failed at %d", i)
+		}
+	}
+	checkVectors(t, tree, vecs)
+	// Splits keep at least minSplitFraction on each side, so depth grows with log_{1/0.9}(n/32).
+	if d := treeDepth(*tree.Root().Load()); d > 48 {
+		t.Fatalf("tree depth %d for %d vectors", d, len(vecs))
+	}
+}
+
+func TestNewTree_PersistPathLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Model = ModelFingerprint{Name: "bge-small-zh-v1.5"}
+	tree := NewTree(cfg)
+	defer tree.Pool().Close()
+	for i, v := range randomVectors(20, 88) {
+		tree.Add(v, uint64(i))
+	}
+	path := filepath.Join(dir, "index.bin")
+	if err := tree.SaveTo(path); err != nil {
+		t.Fatal(err)
+	}
+	enc := filepath.Join(dir, "enc.bin")
+	if err := tree.SaveTo(enc, WithEncryptionKey(bytes.Repeat([]byte{1}, 32))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing file starts an empty tree.
+	missing := *cfg
+	missing.PersistPath = filepath.Join(dir, "missing.bin")
+	empty := NewTree(&missing)
+	if empty.Pool() == nil || empty.Root().Load() != nil {
+		t.Fatal("missing PersistPath: want an empty writable tree")
+	}
+	empty.Pool().Close()
+
+	// An existing file that fails to load must not come back as an empty tree.
+	other := *cfg
+	other.Model = ModelFingerprint{Name: "text-embedding-3-small"}
+	for _, tc := range []struct {
+		path string
+		cfg  Config
+		want error
+	}{
+		{path, other, ErrModelMismatch},
+		{enc, *cfg, store.ErrEncrypted},
+	} {
+		c := tc.cfg
+		c.PersistPath = tc.path
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, tc.want) {
+					t.Errorf("%s: panic %v, want %v", filepath.Base(tc.path), err, tc.want)
+				}
+			}()
+			NewTree(&c).ClosePersisted()
+		}()
+	}
+}
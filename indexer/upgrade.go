@@ -0,0 +1,53 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// Upgrade rewrites the index at srcPath in the current file format (store.FormatVersion) to
+// dstPath. IDs, vectors and the tree structure are preserved; metadata recorded in the source is
+// carried over. srcPath and dstPath may be the same file, which is then replaced atomically.
+//
+// Only metadata the source recorded (or WithMetadata supplies) is written, plus MetaUpgradedAt and
+// the block layout: files older than the metadata section get no created_at and no build config,
+// so record the real values with WithMetadata (MetaSplitThreshold etc.) if they are known. With
+// WithEncryptionKey the result is encrypted; encrypted sources are not supported.
+func Upgrade(srcPath, dstPath string, opts ...SaveOption) error {
+	h, err := store.ReadHeader(srcPath)
+	if err != nil {
+		return err
+	}
+	same := samePath(srcPath, dstPath)
+	if same && h.Version == store.FormatVersion && len(opts) == 0 {
+		return nil
+	}
+	cfg := DefaultConfig()
+	if same {
+		// The mapping would pin the file being replaced, so copy blocks to the heap first.
+		cfg.LoadMode = LoadHeap
+	}
+	t, err := NewTreeFromFile(srcPath, cfg)
+	if err != nil {
+		return err
+	}
+	defer t.ClosePersisted()
+	return t.SaveToAtomic(dstPath, append(opts, upgradeSave())...)
+}
+
+func samePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
@@ -0,0 +1,191 @@
+package indexer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ic-timon/da-hvri/indexer/store"
+)
+
+// leafIDs returns the IDs of each leaf in pre-order, which pins down the tree shape.
+func leafIDs(n Node) [][]uint64 {
+	if n.IsLeaf() {
+		return [][]uint64{append([]uint64(nil), n.(*LeafNode).ids...)}
+	}
+	internal := n.(*InternalNode)
+	var out [][]uint64
+	for i := range internal.children {
+		if child := internal.Child(i); child != nil {
+			out = append(out, leafIDs(child)...)
+		}
+	}
+	return out
+}
+
+// testdata/index_v<N>.bin hold randomVectors(40, 2024) with IDs 0..39 and SplitThreshold 16,
+// saved by the last release writing format version N (v1 with 64 vectors per block, later ones
+// with 8). They are never regenerated: they stand for files already on disk.
+func TestPersist_GoldenUpgrade(t *testing.T) {
+	vecs := randomVectors(40, 2024)
+	for v := store.MinFormatVersion; v <= store.FormatVersion; v++ {
+		src := filepath.Join("testdata", fmt.Sprintf("index_v%d.bin", v))
+		h, err := store.ReadHeader(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Version != v {
+			t.Fatalf("%s: version %d", src, h.Version)
+		}
+		var shape [][]uint64
+		for _, mode := range []LoadMode{LoadMmap, LoadHeap, LoadPread} {
+			cfg := DefaultConfig()
+			cfg.LoadMode = mode
+			tree, err := NewTreeFromFile(src, cfg)
+			if err != nil {
+				t.Fatalf("%s mode %d: %v", src, mode, err)
+			}
+			checkVectors(t, tree, vecs)
+			got := leafIDs(*tree.Root().Load())
+			if shape == nil {
+				shape = got
+			} else if !reflect.DeepEqual(got, shape) {
+				t.Fatalf("%s mode %d: leaf IDs %v, want %v", src, mode, got, shape)
+			}
+			tree.ClosePersisted()
+		}
+		if len(shape) < 2 {
+			t.Fatalf("%s: want a split tree, got %d leaves", src, len(shape))
+		}
+
+		dst := filepath.Join(t.TempDir(), "upgraded.bin")
+		if err := Upgrade(src, dst); err != nil {
+			t.Fatal(err)
+		}
+		h, err = store.ReadHeader(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Version != store.FormatVersion {
+			t.Fatalf("upgraded %s: version %d", src, h.Version)
+		}
+		tree, err := NewTreeFromFile(dst, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkVectors(t, tree, vecs)
+		if got := leafIDs(*tree.Root().Load()); !reflect.DeepEqual(got, shape) {
+			t.Fatalf("upgraded %s: leaf IDs %v, want %v", src, got, shape)
+		}
+		if tree.cfg.VectorsPerBlock != int(h.VectorsPerBlock) {
+			t.Fatalf("upgraded %s: vectors per block %d", src, tree.cfg.VectorsPerBlock)
+		}
+		tree.ClosePersisted()
+	}
+}
+
+func TestPersist_UpgradeInPlace(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join("testdata", "index_v1.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "index.bin")
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := WithMetadata(store.Metadata{MetaSplitThreshold: int64(16)})
+	if err := Upgrade(path, path, meta); err != nil {
+		t.Fatal(err)
+	}
+	h, err := store.ReadHeader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != store.FormatVersion {
+		t.Fatalf("version %d", h.Version)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Already current: a second in-place upgrade leaves the file alone.
+	if err := Upgrade(path, path); err != nil {
+		t.Fatal(err)
+	}
+	info2, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info2.ModTime().Equal(info.ModTime()) {
+		t.Fatal("current file was rewritten")
+	}
+	tree, err := NewTreeFromFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.ClosePersisted()
+	checkVectors(t, tree, randomVectors(40, 2024))
+	if tree.cfg.SplitThreshold != 16 {
+		t.Fatalf("split threshold %d, want 16 from WithMetadata", tree.cfg.SplitThreshold)
+	}
+}
+
+func TestPersist_UpgradeMetadata(t *testing.T) {
+	dir := t.TempDir()
+	// The v1 file records no metadata: nothing but the upgrade time and block layout is invented.
+	dst := filepath.Join(dir, "upgraded.bin")
+	if err := Upgrade(filepath.Join("testdata", "index_v1.bin"), dst); err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.ReadMetadata(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{MetaCreatedAt, MetaSplitThreshold, MetaSearchWidth, MetaPruneEpsilon} {
+		if _, ok := m[k]; ok {
+			t.Fatalf("upgraded file has %s = %v", k, m[k])
+		}
+	}
+	if _, ok := m.Time(MetaUpgradedAt); !ok {
+		t.Fatalf("no %s in %v", MetaUpgradedAt, m)
+	}
+	if v, ok := m.Int64(MetaVectorsPerBlock); !ok || v != 64 {
+		t.Fatalf("%s = %v", MetaVectorsPerBlock, m[MetaVectorsPerBlock])
+	}
+	// ConfigFromFile finds no recorded knobs and keeps the caller's.
+	cfg := DefaultConfig()
+	cfg.SplitThreshold = 16
+	tree, err := NewTreeFromFile(dst, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.cfg.SplitThreshold != 16 {
+		t.Fatalf("split threshold %d, want the caller's 16", tree.cfg.SplitThreshold)
+	}
+
+	// Recorded metadata, created_at included, is carried over as is.
+	src := filepath.Join(dir, "src.bin")
+	if err := tree.SaveToAtomic(src, WithMetadata(store.Metadata{"corpus": "c1"})); err != nil {
+		t.Fatal(err)
+	}
+	tree.ClosePersisted()
+	want, err := store.ReadMetadata(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Upgrade(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.ReadMetadata(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// src carries the first upgrade's time; the second replaces it.
+	delete(got, MetaUpgradedAt)
+	delete(want, MetaUpgradedAt)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("metadata %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build cgo
+
+package simd
+
+// cgoBuild reports whether the C kernels are built (otherwise the Go assembly kernels are).
+const cgoBuild = true
@@ -0,0 +1,231 @@
+//go:build amd64 && !cgo
+
+#include "textflag.h"
+
+// Go assembly counterparts of the CGO kernels in dot_avx2.go, dot_avx512.go,
+// dot_batch_avx2.go and dot_batch_avx512.go. Sums are accumulated in float32
+// like the C versions and widened to float64 on return.
+
+// func dotProductAVX2(a, b []float32) float64
+TEXT ·dotProductAVX2(SB), NOSPLIT, $0-56
+	MOVQ a_base+0(FP), SI
+	MOVQ b_base+24(FP), DI
+	MOVQ a_len+8(FP), CX
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+
+avx2_loop32:
+	CMPQ CX, $32
+	JL   avx2_loop8
+	VMOVUPS (SI), Y4
+	VMOVUPS 32(SI), Y5
+	VMOVUPS 64(SI), Y6
+	VMOVUPS 96(SI), Y7
+	VFMADD231PS (DI), Y4, Y0
+	VFMADD231PS 32(DI), Y5, Y1
+	VFMADD231PS 64(DI), Y6, Y2
+	VFMADD231PS 96(DI), Y7, Y3
+	ADDQ $128, SI
+	ADDQ $128, DI
+	SUBQ $32, CX
+	JMP  avx2_loop32
+
+avx2_loop8:
+	CMPQ CX, $8
+	JL   avx2_reduce
+	VMOVUPS (SI), Y4
+	VFMADD231PS (DI), Y4, Y0
+	ADDQ $32, SI
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  avx2_loop8
+
+avx2_reduce:
+	VADDPS       Y1, Y0, Y0
+	VADDPS       Y3, Y2, Y2
+	VADDPS       Y2, Y0, Y0
+	VEXTRACTF128 $1, Y0, X1
+	VADDPS       X1, X0, X0
+	VHADDPS      X0, X0, X0
+	VHADDPS      X0, X0, X0
+	VZEROUPPER
+
+avx2_tail:
+	TESTQ CX, CX
+	JE    avx2_done
+	VMOVSS (SI), X1
+	VFMADD231SS (DI), X1, X0
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  avx2_tail
+
+avx2_done:
+	VCVTSS2SD X0, X0, X0
+	MOVSD     X0, ret+48(FP)
+	RET
+
+// func dotProductAVX512(a, b []float32) float64
+TEXT ·dotProductAVX512(SB), NOSPLIT, $0-56
+	MOVQ a_base+0(FP), SI
+	MOVQ b_base+24(FP), DI
+	MOVQ a_len+8(FP), CX
+	VPXORD Z0, Z0, Z0
+	VPXORD Z1, Z1, Z1
+	VPXORD Z2, Z2, Z2
+	VPXORD Z3, Z3, Z3
+
+avx512_loop64:
+	CMPQ CX, $64
+	JL   avx512_loop16
+	VMOVUPS (SI), Z4
+	VMOVUPS 64(SI), Z5
+	VMOVUPS 128(SI), Z6
+	VMOVUPS 192(SI), Z7
+	VFMADD231PS (DI), Z4, Z0
+	VFMADD231PS 64(DI), Z5, Z1
+	VFMADD231PS 128(DI), Z6, Z2
+	VFMADD231PS 192(DI), Z7, Z3
+	ADDQ $256, SI
+	ADDQ $256, DI
+	SUBQ $64, CX
+	JMP  avx512_loop64
+
+avx512_loop16:
+	CMPQ CX, $16
+	JL   avx512_reduce
+	VMOVUPS (SI), Z4
+	VFMADD231PS (DI), Z4, Z0
+	ADDQ $64, SI
+	ADDQ $64, DI
+	SUBQ $16, CX
+	JMP  avx512_loop16
+
+avx512_reduce:
+	VADDPS        Z1, Z0, Z0
+	VADDPS        Z3, Z2, Z2
+	VADDPS        Z2, Z0, Z0
+	VEXTRACTF64X4 $1, Z0, Y1
+	VADDPS        Y1, Y0, Y0
+	VEXTRACTF128  $1, Y0, X1
+	VADDPS        X1, X0, X0
+	VHADDPS       X0, X0, X0
+	VHADDPS       X0, X0, X0
+	VZEROUPPER
+
+avx512_tail:
+	TESTQ CX, CX
+	JE    avx512_done
+	VMOVSS (SI), X1
+	VFMADD231SS (DI), X1, X0
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  avx512_tail
+
+avx512_done:
+	VCVTSS2SD X0, X0, X0
+	MOVSD     X0, ret+48(FP)
+	RET
+
+// func dotBatchAVX2(query, data *float32, n int, results *float64)
+// Each of the n vectors is 512 floats. The start of the vector two ahead is
+// prefetched before the current one is summed, like DotProductBatchFlatPrefetchAVX2.
+TEXT ·dotBatchAVX2(SB), NOSPLIT, $0-32
+	MOVQ query+0(FP), SI
+	MOVQ data+8(FP), DI
+	MOVQ n+16(FP), BX
+	MOVQ results+24(FP), R8
+
+avx2_batch_vec:
+	TESTQ BX, BX
+	JE    avx2_batch_done
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+	XORQ   AX, AX
+	PREFETCHT0 4096(DI)
+
+avx2_batch_inner:
+	VMOVUPS (SI)(AX*1), Y4
+	VMOVUPS 32(SI)(AX*1), Y5
+	VMOVUPS 64(SI)(AX*1), Y6
+	VMOVUPS 96(SI)(AX*1), Y7
+	VFMADD231PS (DI)(AX*1), Y4, Y0
+	VFMADD231PS 32(DI)(AX*1), Y5, Y1
+	VFMADD231PS 64(DI)(AX*1), Y6, Y2
+	VFMADD231PS 96(DI)(AX*1), Y7, Y3
+	ADDQ $128, AX
+	CMPQ AX, $2048
+	JL   avx2_batch_inner
+
+	VADDPS       Y1, Y0, Y0
+	VADDPS       Y3, Y2, Y2
+	VADDPS       Y2, Y0, Y0
+	VEXTRACTF128 $1, Y0, X1
+	VADDPS       X1, X0, X0
+	VHADDPS      X0, X0, X0
+	VHADDPS      X0, X0, X0
+	VCVTSS2SD    X0, X0, X0
+	VMOVSD       X0, (R8)
+	ADDQ $2048, DI
+	ADDQ $8, R8
+	DECQ BX
+	JMP  avx2_batch_vec
+
+avx2_batch_done:
+	VZEROUPPER
+	RET
+
+// func dotBatchAVX512(query, data *float32, n int, results *float64)
+TEXT ·dotBatchAVX512(SB), NOSPLIT, $0-32
+	MOVQ query+0(FP), SI
+	MOVQ data+8(FP), DI
+	MOVQ n+16(FP), BX
+	MOVQ results+24(FP), R8
+
+avx512_batch_vec:
+	TESTQ BX, BX
+	JE    avx512_batch_done
+	VPXORD Z0, Z0, Z0
+	VPXORD Z1, Z1, Z1
+	VPXORD Z2, Z2, Z2
+	VPXORD Z3, Z3, Z3
+	XORQ   AX, AX
+	PREFETCHT0 4096(DI)
+
+avx512_batch_inner:
+	VMOVUPS (SI)(AX*1), Z4
+	VMOVUPS 64(SI)(AX*1), Z5
+	VMOVUPS 128(SI)(AX*1), Z6
+	VMOVUPS 192(SI)(AX*1), Z7
+	VFMADD231PS (DI)(AX*1), Z4, Z0
+	VFMADD231PS 64(DI)(AX*1), Z5, Z1
+	VFMADD231PS 128(DI)(AX*1), Z6, Z2
+	VFMADD231PS 192(DI)(AX*1), Z7, Z3
+	ADDQ $256, AX
+	CMPQ AX, $2048
+	JL   avx512_batch_inner
+
+	VADDPS        Z1, Z0, Z0
+	VADDPS        Z3, Z2, Z2
+	VADDPS        Z2, Z0, Z0
+	VEXTRACTF64X4 $1, Z0, Y1
+	VADDPS        Y1, Y0, Y0
+	VEXTRACTF128  $1, Y0, X1
+	VADDPS        X1, X0, X0
+	VHADDPS       X0, X0, X0
+	VHADDPS       X0, X0, X0
+	VCVTSS2SD     X0, X0, X0
+	VMOVSD        X0, (R8)
+	ADDQ $2048, DI
+	ADDQ $8, R8
+	DECQ BX
+	JMP  avx512_batch_vec
+
+avx512_batch_done:
+	VZEROUPPER
+	RET
@@ -0,0 +1,99 @@
+//go:build arm64 && !cgo
+
+#include "textflag.h"
+
+// Go assembly counterparts of the CGO kernels in dot_neon.go and dot_batch_neon.go.
+// Sums are accumulated in float32 like the C versions and widened to float64 on return.
+// FADD/FADDP are emitted as WORDs for assemblers without the vector mnemonics.
+
+#define REDUCE_V0 \
+	WORD $0x4e21d400 \ // FADD  V0.4S, V0.4S, V1.4S
+	WORD $0x4e23d442 \ // FADD  V2.4S, V2.4S, V3.4S
+	WORD $0x4e22d400 \ // FADD  V0.4S, V0.4S, V2.4S
+	WORD $0x6e20d400 \ // FADDP V0.4S, V0.4S, V0.4S
+	WORD $0x7e30d800   // FADDP S0, V0.2S
+
+// func dotProductNEON(a, b []float32) float64
+TEXT ·dotProductNEON(SB), NOSPLIT, $0-56
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	MOVD b_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+neon_loop16:
+	CMP  $16, R2
+	BLT  neon_loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V16.S4, V17.S4, V18.S4, V19.S4]
+	VFMLA V4.S4, V16.S4, V0.S4
+	VFMLA V5.S4, V17.S4, V1.S4
+	VFMLA V6.S4, V18.S4, V2.S4
+	VFMLA V7.S4, V19.S4, V3.S4
+	SUB  $16, R2
+	B    neon_loop16
+
+neon_loop4:
+	CMP  $4, R2
+	BLT  neon_reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V16.S4]
+	VFMLA V4.S4, V16.S4, V0.S4
+	SUB  $4, R2
+	B    neon_loop4
+
+neon_reduce:
+	REDUCE_V0
+
+neon_tail:
+	CBZ  R2, neon_done
+	FMOVS.P 4(R0), F4
+	FMOVS.P 4(R1), F5
+	FMADDS F5, F0, F4, F0
+	SUB  $1, R2
+	B    neon_tail
+
+neon_done:
+	FCVTSD F0, F0
+	FMOVD  F0, ret+48(FP)
+	RET
+
+// func dotBatchNEON(query, data *float32, n int, results *float64)
+// Each of the n vectors is 512 floats. The start of the vector two ahead is
+// prefetched before the current one is summed, like DotProductBatchFlatPrefetchNEON.
+TEXT ·dotBatchNEON(SB), NOSPLIT, $0-32
+	MOVD query+0(FP), R0
+	MOVD data+8(FP), R1
+	MOVD n+16(FP), R2
+	MOVD results+24(FP), R3
+
+neon_batch_vec:
+	CBZ  R2, neon_batch_done
+	PRFM 4096(R1), PLDL1KEEP
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+	MOVD R0, R4
+	MOVD $32, R5
+
+neon_batch_inner:
+	VLD1.P 64(R4), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V16.S4, V17.S4, V18.S4, V19.S4]
+	VFMLA V4.S4, V16.S4, V0.S4
+	VFMLA V5.S4, V17.S4, V1.S4
+	VFMLA V6.S4, V18.S4, V2.S4
+	VFMLA V7.S4, V19.S4, V3.S4
+	SUBS $1, R5
+	BNE  neon_batch_inner
+
+	REDUCE_V0
+	FCVTSD  F0, F0
+	FMOVD.P F0, 8(R3)
+	SUB  $1, R2
+	B    neon_batch_vec
+
+neon_batch_done:
+	RET
@@ -0,0 +1,27 @@
+//go:build amd64 && !cgo
+
+package simd
+
+import "unsafe"
+
+// Implemented in dot_amd64.s; the CGO build uses the C kernels of the same names instead.
+
+//go:noescape
+func dotProductAVX2(a, b []float32) float64
+
+//go:noescape
+func dotProductAVX512(a, b []float32) float64
+
+//go:noescape
+func dotBatchAVX2(query, data *float32, n int, results *float64)
+
+//go:noescape
+func dotBatchAVX512(query, data *float32, n int, results *float64)
+
+func dotProductBatchFlatAVX2(query []float32, data []float32, n int, dst []float64) {
+	dotBatchAVX2(unsafe.SliceData(query), unsafe.SliceData(data), n, &dst[0])
+}
+
+func dotProductBatchFlatAVX512(query []float32, data []float32, n int, dst []float64) {
+	dotBatchAVX512(unsafe.SliceData(query), unsafe.SliceData(data), n, &dst[0])
+}
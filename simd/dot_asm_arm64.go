@@ -0,0 +1,17 @@
+//go:build arm64 && !cgo
+
+package simd
+
+import "unsafe"
+
+// Implemented in dot_arm64.s; the CGO build uses the C kernels of the same names instead.
+
+//go:noescape
+func dotProductNEON(a, b []float32) float64
+
+//go:noescape
+func dotBatchNEON(query, data *float32, n int, results *float64)
+
+func dotProductBatchFlatNEON(query []float32, data []float32, n int, dst []float64) {
+	dotBatchNEON(unsafe.SliceData(query), unsafe.SliceData(data), n, &dst[0])
+}
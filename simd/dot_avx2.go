@@ -3,10 +3,11 @@
 package simd
 
 /*
-#cgo CFLAGS: -mavx2 -O3
+#cgo CFLAGS: -O3
 #include <immintrin.h>
 #include <stddef.h>
 
+__attribute__((target("avx2,fma")))
 static float horizontal_sum_m256(__m256 v) {
 	__m128 hi = _mm256_extractf128_ps(v, 1);
 	__m128 lo = _mm256_extractf128_ps(v, 0);
@@ -16,6 +17,7 @@ static float horizontal_sum_m256(__m256 v) {
 	return _mm_cvtss_f32(sum4);
 }
 
+__attribute__((target("avx2,fma")))
 static float DotProductAVX2(const float* a, const float* b, size_t n) {
 	__m256 sum = _mm256_setzero_ps();
 	size_t i = 0;
@@ -3,10 +3,11 @@
 package simd
 
 /*
-#cgo CFLAGS: -mavx512f -O3
+#cgo CFLAGS: -O3
 #include <immintrin.h>
 #include <stddef.h>
 
+__attribute__((target("avx512f")))
 static float DotProductAVX512(const float* a, const float* b, size_t n) {
 	__m512 sum = _mm512_setzero_ps();
 	size_t i = 0;
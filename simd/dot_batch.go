@@ -2,14 +2,6 @@ package simd
 
 const Dim = 512 // Vector dimension (512).
 
-var dotProductBatchFlatImpl func(query []float32, data []float32, n int) []float64
-
-func init() {
-	if dotProductBatchFlatImpl == nil {
-		dotProductBatchFlatImpl = dotProductBatchFlatGo
-	}
-}
-
 // DotProductBatchFlat computes dot products of n vectors with query.
 // Layout: data[i*Dim:(i+1)*Dim] is the i-th vector. Returns []float64 of length n.
 // Uses SIMD when available (AVX-512, AVX2, SSE4, NEON).
@@ -17,19 +9,21 @@ func DotProductBatchFlat(query []float32, data []float32, n int) []float64 {
 	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
 		return nil
 	}
-	if dotProductBatchFlatImpl != nil {
-		return dotProductBatchFlatImpl(query, data, n)
-	}
-	return dotProductBatchFlatGo(query, data, n)
+	return DotProductBatchFlatInto(query, data, n, make([]float64, n))
 }
 
-func dotProductBatchFlatGo(query []float32, data []float32, n int) []float64 {
-	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
+// DotProductBatchFlatInto is DotProductBatchFlat writing into dst instead of allocating.
+// Returns dst[:n], or nil if the arguments are invalid or len(dst) < n.
+func DotProductBatchFlatInto(query []float32, data []float32, n int, dst []float64) []float64 {
+	if len(query) != Dim || n <= 0 || len(data) < n*Dim || len(dst) < n {
 		return nil
 	}
-	results := make([]float64, n)
+	active.Load().batch(query, data, n, dst)
+	return dst[:n]
+}
+
+func dotProductBatchFlatGo(query []float32, data []float32, n int, dst []float64) {
 	for i := 0; i < n; i++ {
-		results[i] = DotProduct(query, data[i*Dim:(i+1)*Dim])
+		dst[i] = dotProductGo(query, data[i*Dim:(i+1)*Dim])
 	}
-	return results
 }
@@ -3,10 +3,11 @@
 package simd
 
 /*
-#cgo CFLAGS: -mavx2 -O3
+#cgo CFLAGS: -O3
 #include <immintrin.h>
 #include <stddef.h>
 
+__attribute__((target("avx2,fma")))
 static float horizontal_sum_m256(__m256 v) {
 	__m128 hi = _mm256_extractf128_ps(v, 1);
 	__m128 lo = _mm256_extractf128_ps(v, 0);
@@ -16,6 +17,7 @@ static float horizontal_sum_m256(__m256 v) {
 	return _mm_cvtss_f32(sum4);
 }
 
+__attribute__((target("avx2,fma")))
 void DotProductBatchFlatPrefetchAVX2(const float* query, const float* data, int n, double* results) {
 	const size_t dim = 512;
 	for (int i = 0; i < n; i++) {
@@ -41,16 +43,11 @@ import "C"
 
 import "unsafe"
 
-func dotProductBatchFlatAVX2(query []float32, data []float32, n int) []float64 {
-	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
-		return nil
-	}
-	results := make([]float64, n)
+func dotProductBatchFlatAVX2(query []float32, data []float32, n int, dst []float64) {
 	C.DotProductBatchFlatPrefetchAVX2(
 		(*C.float)(unsafe.Pointer(&query[0])),
 		(*C.float)(unsafe.Pointer(&data[0])),
 		C.int(n),
-		(*C.double)(unsafe.Pointer(&results[0])),
+		(*C.double)(unsafe.Pointer(&dst[0])),
 	)
-	return results
 }
@@ -3,13 +3,15 @@
 package simd
 
 /*
-#cgo CFLAGS: -mavx512f -mavx512dq -O3
+#cgo CFLAGS: -O3
 #include <immintrin.h>
 #include <stddef.h>
 
+__attribute__((target("avx512f")))
 static float horizontal_sum_m512(__m512 v) {
-	__m256 hi = _mm512_extractf32x8_ps(v, 1);
-	__m256 lo = _mm512_extractf32x8_ps(v, 0);
+	// The 256-bit halves via the 64-bit extract: _mm512_extractf32x8_ps needs AVX-512DQ.
+	__m256 hi = _mm256_castpd_ps(_mm512_extractf64x4_pd(_mm512_castps_pd(v), 1));
+	__m256 lo = _mm512_castps512_ps256(v);
 	__m256 sum8 = _mm256_add_ps(hi, lo);
 	__m128 hi4 = _mm256_extractf128_ps(sum8, 1);
 	__m128 lo4 = _mm256_extractf128_ps(sum8, 0);
@@ -19,8 +21,9 @@ static float horizontal_sum_m512(__m512 v) {
 	return _mm_cvtss_f32(sum4);
 }
 
-// DotProductBatchFlatPrefetch 对连续内存中的 n 个向量分别与 query 计算点积，循环内预取下一块
-void DotProductBatchFlatPrefetch(const float* query, const float* data, int n, double* results) {
+// DotProductBatchFlatPrefetchAVX512 对连续内存中的 n 个向量分别与 query 计算点积，循环内预取下一块
+__attribute__((target("avx512f")))
+void DotProductBatchFlatPrefetchAVX512(const float* query, const float* data, int n, double* results) {
 	const size_t dim = 512;
 	for (int i = 0; i < n; i++) {
 		if (i + 2 < n) {
@@ -45,16 +48,11 @@ import "C"
 
 import "unsafe"
 
-func dotProductBatchFlatAVX512(query []float32, data []float32, n int) []float64 {
-	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
-		return nil
-	}
-	results := make([]float64, n)
-	C.DotProductBatchFlatPrefetch(
+func dotProductBatchFlatAVX512(query []float32, data []float32, n int, dst []float64) {
+	C.DotProductBatchFlatPrefetchAVX512(
 		(*C.float)(unsafe.Pointer(&query[0])),
 		(*C.float)(unsafe.Pointer(&data[0])),
 		C.int(n),
-		(*C.double)(unsafe.Pointer(&results[0])),
+		(*C.double)(unsafe.Pointer(&dst[0])),
 	)
-	return results
 }
@@ -1,7 +0,0 @@
-//go:build amd64 && !cgo
-
-package simd
-
-func init() {
-	dotProductBatchFlatImpl = dotProductBatchFlatGo
-}
@@ -1,7 +0,0 @@
-//go:build arm64 && !cgo
-
-package simd
-
-func init() {
-	dotProductBatchFlatImpl = dotProductBatchFlatGo
-}
@@ -0,0 +1,66 @@
+package simd
+
+import "unsafe"
+
+// multiTile is the number of queries a DotProductBatchMulti kernel scores per pass over the data.
+const multiTile = 4
+
+// DotProductBatchMulti computes the len(queries)×n score tile of queries against n vectors laid out
+// as in DotProductBatchFlat: out[q*n+i] is the dot product of queries[q] with the i-th vector.
+// out must hold len(queries)*n values; it returns out[:len(queries)*n], or nil on invalid input.
+// Queries are scored four at a time while the vector is in registers, so each block is read from
+// memory once per four queries rather than once per query (AVX-512, AVX2, NEON; Go otherwise).
+func DotProductBatchMulti(queries [][]float32, data []float32, n int, out []float64) []float64 {
+	if n <= 0 || len(out) < len(queries)*n {
+		return nil
+	}
+	return DotProductBatchMultiStrided(queries, data, n, out[:len(queries)*n], n)
+}
+
+// DotProductBatchMultiStrided is DotProductBatchMulti with rows stride values apart: out[q*stride+i]
+// is the dot product of queries[q] with the i-th vector, so a block can be scored straight into
+// per-query rows of a larger score matrix. out must hold (len(queries)-1)*stride+n values; it
+// returns out, or nil on invalid input.
+func DotProductBatchMultiStrided(queries [][]float32, data []float32, n int, out []float64, stride int) []float64 {
+	nq := len(queries)
+	if nq == 0 || n <= 0 || stride < n || len(data) < n*Dim || len(out) < (nq-1)*stride+n {
+		return nil
+	}
+	for _, q := range queries {
+		if len(q) != Dim {
+			return nil
+		}
+	}
+	multi4 := active.Load().multi4
+	if multi4 == nil {
+		dotProductBatchMultiGo(queries, data, n, out, stride)
+		return out
+	}
+	var qp [multiTile]*float32
+	var op [multiTile]*float64
+	for base := 0; base < nq; base += multiTile {
+		// The last tile overlaps the previous one instead of running short; with fewer than
+		// multiTile queries the last query fills the spare slots.
+		if base+multiTile > nq && nq >= multiTile {
+			base = nq - multiTile
+		}
+		for j := 0; j < multiTile; j++ {
+			q := min(base+j, nq-1)
+			qp[j] = unsafe.SliceData(queries[q])
+			op[j] = &out[q*stride]
+		}
+		multi4(qp, unsafe.SliceData(data), n, op)
+	}
+	return out
+}
+
+// dotProductBatchMultiGo scores all queries against one vector before moving to the next, so the
+// vector stays in L1 while it is reused.
+func dotProductBatchMultiGo(queries [][]float32, data []float32, n int, out []float64, stride int) {
+	for i := 0; i < n; i++ {
+		v := data[i*Dim : (i+1)*Dim]
+		for q, query := range queries {
+			out[q*stride+i] = DotProduct(query, v)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build amd64 && !cgo
+
+package simd
+
+// Implemented in dot_multi_amd64.s; the CGO build uses the C kernels of the same names instead.
+
+//go:noescape
+func dotMulti4AVX512(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64)
+
+//go:noescape
+func dotMulti4AVX2(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64)
@@ -0,0 +1,8 @@
+//go:build arm64 && !cgo
+
+package simd
+
+// Implemented in dot_multi_arm64.s; the CGO build uses the C kernel of the same name instead.
+
+//go:noescape
+func dotMulti4NEON(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64)
@@ -0,0 +1,56 @@
+//go:build amd64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <immintrin.h>
+#include <stddef.h>
+
+__attribute__((target("avx2,fma")))
+static float horizontal_sum_m256(__m256 v) {
+	__m128 hi = _mm256_extractf128_ps(v, 1);
+	__m128 lo = _mm256_extractf128_ps(v, 0);
+	__m128 sum4 = _mm_add_ps(hi, lo);
+	sum4 = _mm_hadd_ps(sum4, sum4);
+	sum4 = _mm_hadd_ps(sum4, sum4);
+	return _mm_cvtss_f32(sum4);
+}
+
+// DotMulti4AVX2 对 n 个连续向量分别与 4 个 query 计算点积，每个向量只加载一次（需 FMA，-mfma 不在 cgo 允许的编译参数内）
+__attribute__((target("avx2,fma")))
+void DotMulti4AVX2(const float* q0, const float* q1, const float* q2, const float* q3,
+		const float* data, int n, double* o0, double* o1, double* o2, double* o3) {
+	const size_t dim = 512;
+	for (int i = 0; i < n; i++) {
+		const float* d = data + (size_t)i * dim;
+		if (i + 2 < n) {
+			_mm_prefetch((const char*)(d + 2 * dim), _MM_HINT_T0);
+		}
+		__m256 s0 = _mm256_setzero_ps();
+		__m256 s1 = _mm256_setzero_ps();
+		__m256 s2 = _mm256_setzero_ps();
+		__m256 s3 = _mm256_setzero_ps();
+		for (size_t j = 0; j < dim; j += 8) {
+			__m256 vd = _mm256_loadu_ps(d + j);
+			s0 = _mm256_fmadd_ps(_mm256_loadu_ps(q0 + j), vd, s0);
+			s1 = _mm256_fmadd_ps(_mm256_loadu_ps(q1 + j), vd, s1);
+			s2 = _mm256_fmadd_ps(_mm256_loadu_ps(q2 + j), vd, s2);
+			s3 = _mm256_fmadd_ps(_mm256_loadu_ps(q3 + j), vd, s3);
+		}
+		o0[i] = (double)horizontal_sum_m256(s0);
+		o1[i] = (double)horizontal_sum_m256(s1);
+		o2[i] = (double)horizontal_sum_m256(s2);
+		o3[i] = (double)horizontal_sum_m256(s3);
+	}
+}
+*/
+import "C"
+
+func dotMulti4AVX2(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64) {
+	C.DotMulti4AVX2(
+		(*C.float)(queries[0]), (*C.float)(queries[1]), (*C.float)(queries[2]), (*C.float)(queries[3]),
+		(*C.float)(data), C.int(n),
+		(*C.double)(out[0]), (*C.double)(out[1]), (*C.double)(out[2]), (*C.double)(out[3]),
+	)
+}
@@ -0,0 +1,46 @@
+//go:build amd64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <immintrin.h>
+#include <stddef.h>
+
+// DotMulti4AVX512 对 n 个连续向量分别与 4 个 query 计算点积，每个向量只加载一次
+__attribute__((target("avx512f")))
+void DotMulti4AVX512(const float* q0, const float* q1, const float* q2, const float* q3,
+		const float* data, int n, double* o0, double* o1, double* o2, double* o3) {
+	const size_t dim = 512;
+	for (int i = 0; i < n; i++) {
+		const float* d = data + (size_t)i * dim;
+		if (i + 2 < n) {
+			_mm_prefetch((const char*)(d + 2 * dim), _MM_HINT_T0);
+		}
+		__m512 s0 = _mm512_setzero_ps();
+		__m512 s1 = _mm512_setzero_ps();
+		__m512 s2 = _mm512_setzero_ps();
+		__m512 s3 = _mm512_setzero_ps();
+		for (size_t j = 0; j < dim; j += 16) {
+			__m512 vd = _mm512_loadu_ps(d + j);
+			s0 = _mm512_fmadd_ps(_mm512_loadu_ps(q0 + j), vd, s0);
+			s1 = _mm512_fmadd_ps(_mm512_loadu_ps(q1 + j), vd, s1);
+			s2 = _mm512_fmadd_ps(_mm512_loadu_ps(q2 + j), vd, s2);
+			s3 = _mm512_fmadd_ps(_mm512_loadu_ps(q3 + j), vd, s3);
+		}
+		o0[i] = (double)_mm512_reduce_add_ps(s0);
+		o1[i] = (double)_mm512_reduce_add_ps(s1);
+		o2[i] = (double)_mm512_reduce_add_ps(s2);
+		o3[i] = (double)_mm512_reduce_add_ps(s3);
+	}
+}
+*/
+import "C"
+
+func dotMulti4AVX512(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64) {
+	C.DotMulti4AVX512(
+		(*C.float)(queries[0]), (*C.float)(queries[1]), (*C.float)(queries[2]), (*C.float)(queries[3]),
+		(*C.float)(data), C.int(n),
+		(*C.double)(out[0]), (*C.double)(out[1]), (*C.double)(out[2]), (*C.double)(out[3]),
+	)
+}
@@ -0,0 +1,45 @@
+//go:build arm64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <arm_neon.h>
+#include <stddef.h>
+
+// DotMulti4NEON 对 n 个连续向量分别与 4 个 query 计算点积，每个向量只加载一次
+void DotMulti4NEON(const float* q0, const float* q1, const float* q2, const float* q3,
+		const float* data, int n, double* o0, double* o1, double* o2, double* o3) {
+	const size_t dim = 512;
+	for (int i = 0; i < n; i++) {
+		const float* d = data + (size_t)i * dim;
+		if (i + 2 < n) {
+			__builtin_prefetch(d + 2 * dim);
+		}
+		float32x4_t s0 = vdupq_n_f32(0.0f);
+		float32x4_t s1 = vdupq_n_f32(0.0f);
+		float32x4_t s2 = vdupq_n_f32(0.0f);
+		float32x4_t s3 = vdupq_n_f32(0.0f);
+		for (size_t j = 0; j < dim; j += 4) {
+			float32x4_t vd = vld1q_f32(d + j);
+			s0 = vfmaq_f32(s0, vld1q_f32(q0 + j), vd);
+			s1 = vfmaq_f32(s1, vld1q_f32(q1 + j), vd);
+			s2 = vfmaq_f32(s2, vld1q_f32(q2 + j), vd);
+			s3 = vfmaq_f32(s3, vld1q_f32(q3 + j), vd);
+		}
+		o0[i] = (double)vaddvq_f32(s0);
+		o1[i] = (double)vaddvq_f32(s1);
+		o2[i] = (double)vaddvq_f32(s2);
+		o3[i] = (double)vaddvq_f32(s3);
+	}
+}
+*/
+import "C"
+
+func dotMulti4NEON(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64) {
+	C.DotMulti4NEON(
+		(*C.float)(queries[0]), (*C.float)(queries[1]), (*C.float)(queries[2]), (*C.float)(queries[3]),
+		(*C.float)(data), C.int(n),
+		(*C.double)(out[0]), (*C.double)(out[1]), (*C.double)(out[2]), (*C.double)(out[3]),
+	)
+}
@@ -44,16 +44,11 @@ import "C"
 
 import "unsafe"
 
-func dotProductBatchFlatNEON(query []float32, data []float32, n int) []float64 {
-	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
-		return nil
-	}
-	results := make([]float64, n)
+func dotProductBatchFlatNEON(query []float32, data []float32, n int, dst []float64) {
 	C.DotProductBatchFlatPrefetchNEON(
 		(*C.float)(unsafe.Pointer(&query[0])),
 		(*C.float)(unsafe.Pointer(&data[0])),
 		C.int(n),
-		(*C.double)(unsafe.Pointer(&results[0])),
+		(*C.double)(unsafe.Pointer(&dst[0])),
 	)
-	return results
 }
@@ -3,16 +3,18 @@
 package simd
 
 /*
-#cgo CFLAGS: -msse4.1 -O3
+#cgo CFLAGS: -O3
 #include <smmintrin.h>
 #include <stddef.h>
 
+__attribute__((target("sse4.1")))
 static float horizontal_sum_m128(__m128 v) {
 	v = _mm_hadd_ps(v, v);
 	v = _mm_hadd_ps(v, v);
 	return _mm_cvtss_f32(v);
 }
 
+__attribute__((target("sse4.1")))
 void DotProductBatchFlatPrefetchSSE4(const float* query, const float* data, int n, double* results) {
 	const size_t dim = 512;
 	for (int i = 0; i < n; i++) {
@@ -38,16 +40,11 @@ import "C"
 
 import "unsafe"
 
-func dotProductBatchFlatSSE4(query []float32, data []float32, n int) []float64 {
-	if len(query) != Dim || n <= 0 || len(data) < n*Dim {
-		return nil
-	}
-	results := make([]float64, n)
+func dotProductBatchFlatSSE4(query []float32, data []float32, n int, dst []float64) {
 	C.DotProductBatchFlatPrefetchSSE4(
 		(*C.float)(unsafe.Pointer(&query[0])),
 		(*C.float)(unsafe.Pointer(&data[0])),
 		C.int(n),
-		(*C.double)(unsafe.Pointer(&results[0])),
+		(*C.double)(unsafe.Pointer(&dst[0])),
 	)
-	return results
 }
@@ -1,4 +1,4 @@
-//go:build amd64 && cgo
+//go:build amd64
 
 package simd
 
@@ -19,3 +19,11 @@ func BenchmarkDotProduct_AVX2(b *testing.B) {
 		_ = dotProductAVX2(va, vb)
 	}
 }
+
+// BenchmarkPrefetchScan_AVX2 measures block prefetch with the AVX2 batch kernel.
+func BenchmarkPrefetchScan_AVX2(b *testing.B) {
+	if !cpu.X86.HasAVX2 || !cpu.X86.HasFMA {
+		b.Skip("AVX2 not available")
+	}
+	benchPrefetchScan(b, dotProductBatchFlatAVX2)
+}
@@ -0,0 +1,47 @@
+//go:build amd64
+
+package simd
+
+import (
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/cpu"
+)
+
+func BenchmarkDotProduct_AVX512(b *testing.B) {
+	va, vb := initBenchVectors()
+	if !canUseAVX512() {
+		b.Skip("AVX-512 不可用，跳过")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = dotProductAVX512(va, vb)
+	}
+}
+
+func BenchmarkSemanticChunkSim_AVX512(b *testing.B) {
+	va, vb := initBenchVectors()
+	if !canUseAVX512() {
+		b.Skip("AVX-512 不可用，跳过")
+	}
+	n := 3365
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < n; j++ {
+			_ = dotProductAVX512(va, vb)
+		}
+	}
+}
+
+func canUseAVX512() bool {
+	return runtime.GOARCH == "amd64" && cpu.X86.HasAVX512F
+}
+
+// BenchmarkPrefetchScan_AVX512 measures block prefetch with the AVX-512 batch kernel.
+func BenchmarkPrefetchScan_AVX512(b *testing.B) {
+	if !canUseAVX512() {
+		b.Skip("AVX-512 不可用，跳过")
+	}
+	benchPrefetchScan(b, dotProductBatchFlatAVX512)
+}
@@ -1,4 +1,4 @@
-//go:build arm64 && cgo
+//go:build arm64
 
 package simd
 
@@ -10,7 +10,7 @@ import (
 )
 
 func BenchmarkDotProduct_NEON(b *testing.B) {
-	if runtime.GOARCH != "arm64" || !cpu.ARM64.HasNEON {
+	if runtime.GOARCH != "arm64" || !cpu.ARM64.HasASIMD {
 		b.Skip("NEON not available")
 	}
 	va, vb := initBenchVectors()
@@ -19,3 +19,11 @@ func BenchmarkDotProduct_NEON(b *testing.B) {
 		_ = dotProductNEON(va, vb)
 	}
 }
+
+// BenchmarkPrefetchScan_NEON measures block prefetch with the NEON batch kernel.
+func BenchmarkPrefetchScan_NEON(b *testing.B) {
+	if !cpu.ARM64.HasASIMD {
+		b.Skip("NEON not available")
+	}
+	benchPrefetchScan(b, dotProductBatchFlatNEON)
+}
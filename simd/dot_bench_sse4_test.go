@@ -19,3 +19,11 @@ func BenchmarkDotProduct_SSE4(b *testing.B) {
 		_ = dotProductSSE4(va, vb)
 	}
 }
+
+// BenchmarkPrefetchScan_SSE4 measures block prefetch with the SSE4 batch kernel.
+func BenchmarkPrefetchScan_SSE4(b *testing.B) {
+	if !cpu.X86.HasSSE41 {
+		b.Skip("SSE4.1 not available")
+	}
+	benchPrefetchScan(b, dotProductBatchFlatSSE4)
+}
@@ -1,11 +1,9 @@
 package simd
 
 import (
+	"fmt"
 	"math/rand"
-	"runtime"
 	"testing"
-
-	"golang.org/x/sys/cpu"
 )
 
 const dim = 512 // BGE 嵌入维度
@@ -29,17 +27,6 @@ func BenchmarkDotProduct_Go(b *testing.B) {
 	}
 }
 
-func BenchmarkDotProduct_AVX512(b *testing.B) {
-	va, vb := initBenchVectors()
-	if !canUseAVX512() {
-		b.Skip("AVX-512 不可用，跳过")
-	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_ = dotProductAVX512(va, vb)
-	}
-}
-
 func BenchmarkDotProduct_Auto(b *testing.B) {
 	va, vb := initBenchVectors()
 	b.ResetTimer()
@@ -55,9 +42,10 @@ func BenchmarkDotProductBatchFlat_Go(b *testing.B) {
 	for i := 0; i < 64; i++ {
 		copy(data[i*dim:(i+1)*dim], va)
 	}
+	dst := make([]float64, 64)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = dotProductBatchFlatGo(vb, data, 64)
+		dotProductBatchFlatGo(vb, data, 64, dst)
 	}
 }
 
@@ -74,6 +62,22 @@ func BenchmarkDotProductBatchFlat_Auto(b *testing.B) {
 	}
 }
 
+// BenchmarkDotProductBatchFlatInto_Auto benchmarks the auto-dispatched batch implementation
+// writing into a reused buffer.
+func BenchmarkDotProductBatchFlatInto_Auto(b *testing.B) {
+	va, vb := initBenchVectors()
+	data := make([]float32, 64*dim)
+	for i := 0; i < 64; i++ {
+		copy(data[i*dim:(i+1)*dim], va)
+	}
+	dst := make([]float64, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = DotProductBatchFlatInto(vb, data, 64, dst)
+	}
+}
+
 // BenchmarkSemanticChunkSim_Go benchmarks ~3365 dot products (simulated semantic chunk).
 func BenchmarkSemanticChunkSim_Go(b *testing.B) {
 	va, vb := initBenchVectors()
@@ -86,20 +90,134 @@ func BenchmarkSemanticChunkSim_Go(b *testing.B) {
 	}
 }
 
-func BenchmarkSemanticChunkSim_AVX512(b *testing.B) {
-	va, vb := initBenchVectors()
-	if !canUseAVX512() {
-		b.Skip("AVX-512 不可用，跳过")
+// benchMultiVectors is large enough (4MB) that each pass streams the data from beyond L2.
+const benchMultiVectors = 2048
+
+func initBenchMulti(nq int) (queries [][]float32, data []float32) {
+	va, _ := initBenchVectors()
+	data = make([]float32, benchMultiVectors*dim)
+	for i := 0; i < benchMultiVectors; i++ {
+		copy(data[i*dim:(i+1)*dim], va)
+	}
+	queries = make([][]float32, nq)
+	for q := range queries {
+		queries[q] = make([]float32, dim)
+		for j := range queries[q] {
+			queries[q][j] = rand.Float32()*2 - 1
+		}
 	}
-	n := 3365
+	return queries, data
+}
+
+// BenchmarkDotProductBatchMulti_Auto scores 8 queries against the data in one pass.
+func BenchmarkDotProductBatchMulti_Auto(b *testing.B) {
+	queries, data := initBenchMulti(8)
+	out := make([]float64, len(queries)*benchMultiVectors)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		for j := 0; j < n; j++ {
-			_ = dotProductAVX512(va, vb)
+		_ = DotProductBatchMulti(queries, data, benchMultiVectors, out)
+	}
+}
+
+// BenchmarkDotProductBatchMulti_PerQuery is the baseline: one DotProductBatchFlat pass per query.
+func BenchmarkDotProductBatchMulti_PerQuery(b *testing.B) {
+	queries, data := initBenchMulti(8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range queries {
+			_ = DotProductBatchFlat(q, data, benchMultiVectors)
 		}
 	}
 }
 
-func canUseAVX512() bool {
-	return runtime.GOARCH == "amd64" && cpu.X86.HasAVX512F
+// benchPrefetchBlocks blocks of 64 vectors (32MB) are scanned per op in shuffled order, like the
+// scattered blocks of heap leaves, so each block starts a new memory stream.
+const benchPrefetchBlocks = 256
+
+// benchPrefetchScan scans the blocks with kernel. With window > 0 it first prefetches the head
+// (window vectors) of the block distance ahead, as a leaf scan does; window = 64 prefetches the
+// whole block.
+func benchPrefetchScan(b *testing.B, kernel func(query, data []float32, n int, dst []float64)) {
+	va, vb := initBenchVectors()
+	const vpb = 64
+	data := make([]float32, benchPrefetchBlocks*vpb*dim)
+	for i := 0; i < benchPrefetchBlocks*vpb; i++ {
+		copy(data[i*dim:(i+1)*dim], va)
+	}
+	order := rand.New(rand.NewSource(7)).Perm(benchPrefetchBlocks)
+	block := func(i int) []float32 {
+		return data[order[i]*vpb*dim : (order[i]+1)*vpb*dim]
+	}
+	dst := make([]float64, vpb)
+	for _, c := range []struct{ distance, window int }{{0, 0}, {1, 1}, {2, 1}, {4, 1}, {1, vpb}} {
+		b.Run(fmt.Sprintf("distance=%d/window=%d", c.distance, c.window), func(b *testing.B) {
+			b.SetBytes(int64(len(data) * 4))
+			for i := 0; i < b.N; i++ {
+				for bi := 0; bi < benchPrefetchBlocks; bi++ {
+					if c.window > 0 && bi+c.distance < benchPrefetchBlocks {
+						Prefetch(block(bi + c.distance)[:c.window*dim])
+					}
+					kernel(vb, block(bi), vpb, dst)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPrefetchScan_Go measures block prefetch with the pure Go batch kernel.
+func BenchmarkPrefetchScan_Go(b *testing.B) {
+	benchPrefetchScan(b, dotProductBatchFlatGo)
+}
+
+// BenchmarkPrefetchScan_Auto measures block prefetch with the auto-dispatched batch kernel.
+func BenchmarkPrefetchScan_Auto(b *testing.B) {
+	benchPrefetchScan(b, active.Load().batch)
+}
+
+func BenchmarkL2Squared_Go(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = l2SquaredGo(va, vb)
+	}
+}
+
+func BenchmarkL2Squared_Auto(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = L2Squared(va, vb)
+	}
+}
+
+func BenchmarkAddInto_Go(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		addGo(va, vb)
+	}
+}
+
+func BenchmarkAddInto_Auto(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = AddInto(va, vb)
+	}
+}
+
+func BenchmarkScaleInto_Go(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scaleGo(va, vb, 0.5)
+	}
+}
+
+func BenchmarkScaleInto_Auto(b *testing.B) {
+	va, vb := initBenchVectors()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = ScaleInto(va, vb, 0.5)
+	}
 }
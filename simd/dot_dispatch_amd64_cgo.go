@@ -4,18 +4,24 @@ package simd
 
 import "golang.org/x/sys/cpu"
 
-func init() {
-	if cpu.X86.HasAVX512F {
-		dotProductImpl = dotProductAVX512
-		dotProductImplDesc = "AVX-512"
-	} else if cpu.X86.HasAVX2 && cpu.X86.HasFMA {
-		dotProductImpl = dotProductAVX2
-		dotProductImplDesc = "AVX2"
-	} else if cpu.X86.HasSSE41 {
-		dotProductImpl = dotProductSSE4
-		dotProductImplDesc = "SSE4"
-	} else {
-		dotProductImpl = dotProductGo
-		dotProductImplDesc = "Go"
+// archKernels returns the C kernels, best first.
+func archKernels() []kernel {
+	avx2 := cpu.X86.HasAVX2 && cpu.X86.HasFMA
+	return []kernel{
+		{
+			name: "avx512", desc: "AVX-512", batchDesc: "AVX-512 + prefetch", supported: cpu.X86.HasAVX512F,
+			dot: dotProductAVX512, batch: dotProductBatchFlatAVX512, multi4: dotMulti4AVX512,
+			l2sq: l2SquaredAVX512, add: addAVX512, scale: scaleAVX512,
+		},
+		{
+			name: "avx2", desc: "AVX2", batchDesc: "AVX2 + prefetch", supported: avx2,
+			dot: dotProductAVX2, batch: dotProductBatchFlatAVX2, multi4: dotMulti4AVX2,
+			l2sq: l2SquaredAVX2, add: addAVX2, scale: scaleAVX2,
+		},
+		{
+			name: "sse4", desc: "SSE4", batchDesc: "SSE4 + prefetch", supported: cpu.X86.HasSSE41,
+			dot: dotProductSSE4, batch: dotProductBatchFlatSSE4,
+			l2sq: l2SquaredSSE4, add: addSSE4, scale: scaleSSE4,
+		},
 	}
 }
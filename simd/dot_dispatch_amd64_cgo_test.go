@@ -0,0 +1,83 @@
+//go:build amd64 && cgo
+
+package simd
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCKernels_TargetISA disassembles the test binary: cgo compiles every C kernel in one package
+// with the same flags, so only the per-function target attributes keep a pinned sse4 kernel free
+// of VEX/EVEX instructions, an avx2 kernel free of AVX-512 ones and the avx512 kernels (selected
+// on AVX-512F alone) free of AVX-512DQ ones.
+func TestCKernels_TargetISA(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and disassembles a test binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	// The binary go test runs has no symbol table, so build one that does.
+	exe := filepath.Join(t.TempDir(), "simd.test")
+	if out, err := exec.Command(goTool, "test", "-c", "-o", exe, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go test -c: %v\n%s", err, out)
+	}
+	out, err := exec.Command(goTool, "tool", "objdump", "-s",
+		`^(_cgo_\w+_Cfunc_)?(DotProduct|DotProductBatchFlatPrefetch|DotMulti4|L2Squared|Add|Scale)(SSE4|AVX2|AVX512)$`, exe).Output()
+	if err != nil {
+		t.Fatalf("go tool objdump: %v", err)
+	}
+	zmm := regexp.MustCompile(`\b(Z\d+|K[1-7])\b`)
+	ymm := regexp.MustCompile(`\bY\d+\b`)
+	dq := regexp.MustCompile(`^V\w+(32X8|64X2)\b`)
+	seen := map[string]bool{}
+	var sym string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, ok := strings.CutPrefix(line, "TEXT "); ok {
+			sym, _, _ = strings.Cut(name, "(")
+			if _, fn, ok := strings.Cut(sym, "_Cfunc_"); ok {
+				seen[fn] = true
+			} else {
+				seen[sym] = true
+			}
+			continue
+		}
+		var fields []string
+		for _, f := range strings.Split(line, "\t") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		if sym == "" || len(fields) < 4 {
+			continue
+		}
+		inst := fields[3]
+		var bad bool
+		switch {
+		case strings.HasSuffix(sym, "SSE4"):
+			bad = strings.HasPrefix(inst, "V") || ymm.MatchString(inst) || zmm.MatchString(inst)
+		case strings.HasSuffix(sym, "AVX2"):
+			bad = zmm.MatchString(inst)
+		default:
+			bad = dq.MatchString(inst)
+		}
+		bad = bad || strings.HasPrefix(inst, "?")
+		if bad {
+			t.Errorf("%s: %s", sym, inst)
+		}
+	}
+	for _, name := range []string{
+		"DotProductSSE4", "DotProductBatchFlatPrefetchSSE4", "L2SquaredSSE4", "AddSSE4", "ScaleSSE4",
+		"DotProductAVX2", "DotProductBatchFlatPrefetchAVX2", "DotMulti4AVX2", "L2SquaredAVX2", "AddAVX2", "ScaleAVX2",
+		"DotProductAVX512", "DotProductBatchFlatPrefetchAVX512", "DotMulti4AVX512", "L2SquaredAVX512", "AddAVX512", "ScaleAVX512",
+	} {
+		if !seen[name] {
+			t.Errorf("%s not found in the test binary", name)
+		}
+	}
+}
@@ -2,7 +2,21 @@
 
 package simd
 
-func init() {
-	dotProductImpl = dotProductGo
-	dotProductImplDesc = "Go"
+import "golang.org/x/sys/cpu"
+
+// archKernels returns the Go assembly kernels, best first. SSE4 exists only as a C kernel.
+func archKernels() []kernel {
+	avx2 := cpu.X86.HasAVX2 && cpu.X86.HasFMA
+	return []kernel{
+		{
+			name: "avx512", desc: "AVX-512 (asm)", batchDesc: "AVX-512 + prefetch (asm)", supported: cpu.X86.HasAVX512F,
+			dot: dotProductAVX512, batch: dotProductBatchFlatAVX512, multi4: dotMulti4AVX512,
+			l2sq: l2SquaredAVX512, add: addAVX512, scale: scaleAVX512,
+		},
+		{
+			name: "avx2", desc: "AVX2 (asm)", batchDesc: "AVX2 + prefetch (asm)", supported: avx2,
+			dot: dotProductAVX2, batch: dotProductBatchFlatAVX2, multi4: dotMulti4AVX2,
+			l2sq: l2SquaredAVX2, add: addAVX2, scale: scaleAVX2,
+		},
+	}
 }
@@ -4,12 +4,13 @@ package simd
 
 import "golang.org/x/sys/cpu"
 
-func init() {
-	if cpu.ARM64.HasASIMD {
-		dotProductImpl = dotProductNEON
-		dotProductImplDesc = "NEON"
-	} else {
-		dotProductImpl = dotProductGo
-		dotProductImplDesc = "Go"
+// archKernels returns the C kernels, best first.
+func archKernels() []kernel {
+	return []kernel{
+		{
+			name: "neon", desc: "NEON", batchDesc: "NEON + prefetch", supported: cpu.ARM64.HasASIMD,
+			dot: dotProductNEON, batch: dotProductBatchFlatNEON, multi4: dotMulti4NEON,
+			l2sq: l2SquaredNEON, add: addNEON, scale: scaleNEON,
+		},
 	}
 }
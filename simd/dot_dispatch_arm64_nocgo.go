@@ -2,7 +2,15 @@
 
 package simd
 
-func init() {
-	dotProductImpl = dotProductGo
-	dotProductImplDesc = "Go"
+import "golang.org/x/sys/cpu"
+
+// archKernels returns the Go assembly kernels, best first.
+func archKernels() []kernel {
+	return []kernel{
+		{
+			name: "neon", desc: "NEON (asm)", batchDesc: "NEON + prefetch (asm)", supported: cpu.ARM64.HasASIMD,
+			dot: dotProductNEON, batch: dotProductBatchFlatNEON, multi4: dotMulti4NEON,
+			l2sq: l2SquaredNEON, add: addNEON, scale: scaleNEON,
+		},
+	}
 }
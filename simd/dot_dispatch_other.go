@@ -2,7 +2,7 @@
 
 package simd
 
-func init() {
-	dotProductImpl = dotProductGo
-	dotProductImplDesc = "Go"
-}
+// archKernels returns no SIMD kernels; the Go kernel is used.
+func archKernels() []kernel { return nil }
+
+func cpuFeatures() []string { return nil }
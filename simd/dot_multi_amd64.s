@@ -0,0 +1,147 @@
+//go:build amd64 && !cgo
+
+#include "textflag.h"
+
+// Multi-query kernels for DotProductBatchMulti. Each pass scores four queries
+// against every vector: a 32-float slice of the vector is loaded once and
+// multiplied with the matching slice of all four queries, so the block is read
+// from memory once per four queries instead of once per query.
+
+// REDUCE_AVX512 adds accumulator pair za+zb, sums the lanes and stores the
+// float64 result at dst. Clobbers Z8.
+#define REDUCE_AVX512(za, zb, ya, xa, dst) \
+	VADDPS        zb, za, za \
+	VEXTRACTF64X4 $1, za, Y8 \
+	VADDPS        Y8, ya, ya \
+	VEXTRACTF128  $1, ya, X8 \
+	VADDPS        X8, xa, xa \
+	VHADDPS       xa, xa, xa \
+	VHADDPS       xa, xa, xa \
+	VCVTSS2SD     xa, xa, xa \
+	VMOVSD        xa, dst
+
+// REDUCE_AVX2 is REDUCE_AVX512 for ymm accumulators. Clobbers X10.
+#define REDUCE_AVX2(ya, yb, xa, dst) \
+	VADDPS       yb, ya, ya \
+	VEXTRACTF128 $1, ya, X10 \
+	VADDPS       X10, xa, xa \
+	VHADDPS      xa, xa, xa \
+	VHADDPS      xa, xa, xa \
+	VCVTSS2SD    xa, xa, xa \
+	VMOVSD       xa, dst
+
+// func dotMulti4AVX512(queries [4]*float32, data *float32, n int, out [4]*float64)
+TEXT ·dotMulti4AVX512(SB), NOSPLIT, $0-80
+	MOVQ queries_0+0(FP), R8
+	MOVQ queries_1+8(FP), R9
+	MOVQ queries_2+16(FP), R10
+	MOVQ queries_3+24(FP), R11
+	MOVQ out_0+48(FP), CX
+	MOVQ out_1+56(FP), DX
+	MOVQ out_2+64(FP), SI
+	MOVQ out_3+72(FP), R12
+	MOVQ data+32(FP), DI
+	MOVQ n+40(FP), BX
+
+avx512_multi_vec:
+	TESTQ BX, BX
+	JE    avx512_multi_done
+	VPXORD Z0, Z0, Z0
+	VPXORD Z1, Z1, Z1
+	VPXORD Z2, Z2, Z2
+	VPXORD Z3, Z3, Z3
+	VPXORD Z4, Z4, Z4
+	VPXORD Z5, Z5, Z5
+	VPXORD Z6, Z6, Z6
+	VPXORD Z7, Z7, Z7
+	XORQ   AX, AX
+	PREFETCHT0 4096(DI)
+
+avx512_multi_inner:
+	VMOVUPS (DI)(AX*1), Z16
+	VMOVUPS 64(DI)(AX*1), Z17
+	VFMADD231PS (R8)(AX*1), Z16, Z0
+	VFMADD231PS 64(R8)(AX*1), Z17, Z1
+	VFMADD231PS (R9)(AX*1), Z16, Z2
+	VFMADD231PS 64(R9)(AX*1), Z17, Z3
+	VFMADD231PS (R10)(AX*1), Z16, Z4
+	VFMADD231PS 64(R10)(AX*1), Z17, Z5
+	VFMADD231PS (R11)(AX*1), Z16, Z6
+	VFMADD231PS 64(R11)(AX*1), Z17, Z7
+	ADDQ $128, AX
+	CMPQ AX, $2048
+	JL   avx512_multi_inner
+
+	REDUCE_AVX512(Z0, Z1, Y0, X0, (CX))
+	REDUCE_AVX512(Z2, Z3, Y2, X2, (DX))
+	REDUCE_AVX512(Z4, Z5, Y4, X4, (SI))
+	REDUCE_AVX512(Z6, Z7, Y6, X6, (R12))
+	ADDQ $8, CX
+	ADDQ $8, DX
+	ADDQ $8, SI
+	ADDQ $8, R12
+	ADDQ $2048, DI
+	DECQ BX
+	JMP  avx512_multi_vec
+
+avx512_multi_done:
+	VZEROUPPER
+	RET
+
+// func dotMulti4AVX2(queries [4]*float32, data *float32, n int, out [4]*float64)
+TEXT ·dotMulti4AVX2(SB), NOSPLIT, $0-80
+	MOVQ queries_0+0(FP), R8
+	MOVQ queries_1+8(FP), R9
+	MOVQ queries_2+16(FP), R10
+	MOVQ queries_3+24(FP), R11
+	MOVQ out_0+48(FP), CX
+	MOVQ out_1+56(FP), DX
+	MOVQ out_2+64(FP), SI
+	MOVQ out_3+72(FP), R12
+	MOVQ data+32(FP), DI
+	MOVQ n+40(FP), BX
+
+avx2_multi_vec:
+	TESTQ BX, BX
+	JE    avx2_multi_done
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+	VXORPS Y4, Y4, Y4
+	VXORPS Y5, Y5, Y5
+	VXORPS Y6, Y6, Y6
+	VXORPS Y7, Y7, Y7
+	XORQ   AX, AX
+	PREFETCHT0 4096(DI)
+
+avx2_multi_inner:
+	VMOVUPS (DI)(AX*1), Y8
+	VMOVUPS 32(DI)(AX*1), Y9
+	VFMADD231PS (R8)(AX*1), Y8, Y0
+	VFMADD231PS 32(R8)(AX*1), Y9, Y1
+	VFMADD231PS (R9)(AX*1), Y8, Y2
+	VFMADD231PS 32(R9)(AX*1), Y9, Y3
+	VFMADD231PS (R10)(AX*1), Y8, Y4
+	VFMADD231PS 32(R10)(AX*1), Y9, Y5
+	VFMADD231PS (R11)(AX*1), Y8, Y6
+	VFMADD231PS 32(R11)(AX*1), Y9, Y7
+	ADDQ $64, AX
+	CMPQ AX, $2048
+	JL   avx2_multi_inner
+
+	REDUCE_AVX2(Y0, Y1, X0, (CX))
+	REDUCE_AVX2(Y2, Y3, X2, (DX))
+	REDUCE_AVX2(Y4, Y5, X4, (SI))
+	REDUCE_AVX2(Y6, Y7, X6, (R12))
+	ADDQ $8, CX
+	ADDQ $8, DX
+	ADDQ $8, SI
+	ADDQ $8, R12
+	ADDQ $2048, DI
+	DECQ BX
+	JMP  avx2_multi_vec
+
+avx2_multi_done:
+	VZEROUPPER
+	RET
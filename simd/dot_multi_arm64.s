@@ -0,0 +1,81 @@
+//go:build arm64 && !cgo
+
+#include "textflag.h"
+
+// Multi-query kernel for DotProductBatchMulti: an 8-float slice of each vector
+// is loaded once and multiplied with the matching slice of four queries, so the
+// block is read from memory once per four queries instead of once per query.
+// FADD/FADDP are emitted as WORDs for assemblers without the vector mnemonics.
+
+// func dotMulti4NEON(queries [4]*float32, data *float32, n int, out [4]*float64)
+TEXT ·dotMulti4NEON(SB), NOSPLIT, $0-80
+	MOVD queries_0+0(FP), R8
+	MOVD queries_1+8(FP), R9
+	MOVD queries_2+16(FP), R10
+	MOVD queries_3+24(FP), R11
+	MOVD out_0+48(FP), R12
+	MOVD out_1+56(FP), R13
+	MOVD out_2+64(FP), R14
+	MOVD out_3+72(FP), R15
+	MOVD data+32(FP), R1
+	MOVD n+40(FP), R2
+
+neon_multi_vec:
+	CBZ  R2, neon_multi_done
+	PRFM 4096(R1), PLDL1KEEP
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+	VEOR V4.B16, V4.B16, V4.B16
+	VEOR V5.B16, V5.B16, V5.B16
+	VEOR V6.B16, V6.B16, V6.B16
+	VEOR V7.B16, V7.B16, V7.B16
+	MOVD R8, R4
+	MOVD R9, R5
+	MOVD R10, R6
+	MOVD R11, R7
+	MOVD $64, R3
+
+neon_multi_inner:
+	VLD1.P 32(R1), [V16.S4, V17.S4]
+	VLD1.P 32(R4), [V20.S4, V21.S4]
+	VLD1.P 32(R5), [V22.S4, V23.S4]
+	VLD1.P 32(R6), [V24.S4, V25.S4]
+	VLD1.P 32(R7), [V26.S4, V27.S4]
+	VFMLA V20.S4, V16.S4, V0.S4
+	VFMLA V21.S4, V17.S4, V1.S4
+	VFMLA V22.S4, V16.S4, V2.S4
+	VFMLA V23.S4, V17.S4, V3.S4
+	VFMLA V24.S4, V16.S4, V4.S4
+	VFMLA V25.S4, V17.S4, V5.S4
+	VFMLA V26.S4, V16.S4, V6.S4
+	VFMLA V27.S4, V17.S4, V7.S4
+	SUBS $1, R3
+	BNE  neon_multi_inner
+
+	WORD $0x4e21d400 // FADD  V0.4S, V0.4S, V1.4S
+	WORD $0x4e23d442 // FADD  V2.4S, V2.4S, V3.4S
+	WORD $0x4e25d484 // FADD  V4.4S, V4.4S, V5.4S
+	WORD $0x4e27d4c6 // FADD  V6.4S, V6.4S, V7.4S
+	WORD $0x6e20d400 // FADDP V0.4S, V0.4S, V0.4S
+	WORD $0x6e22d442 // FADDP V2.4S, V2.4S, V2.4S
+	WORD $0x6e24d484 // FADDP V4.4S, V4.4S, V4.4S
+	WORD $0x6e26d4c6 // FADDP V6.4S, V6.4S, V6.4S
+	WORD $0x7e30d800 // FADDP S0, V0.2S
+	WORD $0x7e30d842 // FADDP S2, V2.2S
+	WORD $0x7e30d884 // FADDP S4, V4.2S
+	WORD $0x7e30d8c6 // FADDP S6, V6.2S
+	FCVTSD  F0, F0
+	FCVTSD  F2, F2
+	FCVTSD  F4, F4
+	FCVTSD  F6, F6
+	FMOVD.P F0, 8(R12)
+	FMOVD.P F2, 8(R13)
+	FMOVD.P F4, 8(R14)
+	FMOVD.P F6, 8(R15)
+	SUB  $1, R2
+	B    neon_multi_vec
+
+neon_multi_done:
+	RET
@@ -3,16 +3,18 @@
 package simd
 
 /*
-#cgo CFLAGS: -msse4.1 -O3
+#cgo CFLAGS: -O3
 #include <smmintrin.h>
 #include <stddef.h>
 
+__attribute__((target("sse4.1")))
 static float horizontal_sum_m128(__m128 v) {
 	v = _mm_hadd_ps(v, v);
 	v = _mm_hadd_ps(v, v);
 	return _mm_cvtss_f32(v);
 }
 
+__attribute__((target("sse4.1")))
 static float DotProductSSE4(const float* a, const float* b, size_t n) {
 	__m128 sum = _mm_setzero_ps();
 	size_t i = 0;
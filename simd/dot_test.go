@@ -0,0 +1,375 @@
+package simd
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Every kernel built for this GOARCH and CPU is checked against a float64 reference. The kernels
+// accumulate in float32 in different orders (lane-wise partial sums, FMA or not, pairs in the Go
+// kernel), so a result is accepted within the float32 summation bound
+//
+//	|got - ref| <= (n+2)·2⁻²³·Σ|aᵢbᵢ| + (n+2)·2⁻¹⁴⁹
+//
+// where the second term covers products and partial sums that fall into the denormal range.
+
+// forEachKernel runs fn as a subtest with each supported kernel active, restoring the kernel in use.
+func forEachKernel(t *testing.T, fn func(t *testing.T, k kernel)) {
+	defer active.Store(active.Load())
+	for _, k := range supportedKernels() {
+		t.Run(k.name, func(t *testing.T) {
+			useKernel(k, true)
+			fn(t, k)
+		})
+	}
+}
+
+func supportedKernels() []kernel {
+	var ks []kernel
+	for _, k := range kernels {
+		if k.supported {
+			ks = append(ks, k)
+		}
+	}
+	return ks
+}
+
+// refDot is the dot product in float64 (each float32 product is exact) with Σ|aᵢbᵢ|.
+func refDot(a, b []float32) (dot, abs float64) {
+	for i := range a {
+		p := float64(a[i]) * float64(b[i])
+		dot += p
+		abs += math.Abs(p)
+	}
+	return dot, abs
+}
+
+// checkDot reports whether got is an acceptable dot product of a and b: NaN if an input is NaN,
+// within the summation bound if the inputs are finite and no partial sum can overflow float32.
+// Other inputs (infinities, overflow) are only required not to crash.
+func checkDot(a, b []float32, got float64) (ok bool, ref, tol float64) {
+	ref, abs := refDot(a, b)
+	for i := range a {
+		if math.IsNaN(float64(a[i])) || math.IsNaN(float64(b[i])) {
+			return math.IsNaN(got), math.NaN(), 0
+		}
+	}
+	if math.IsNaN(abs) || math.IsInf(abs, 0) || abs > math.MaxFloat32/2 {
+		return true, ref, math.Inf(1)
+	}
+	n := float64(len(a) + 2)
+	tol = n*0x1p-23*abs + n*0x1p-149
+	return math.Abs(got-ref) <= tol, ref, tol
+}
+
+func expectDot(t *testing.T, what string, a, b []float32, got float64) {
+	t.Helper()
+	if ok, ref, tol := checkDot(a, b, got); !ok {
+		t.Fatalf("%s (len %d): got %g, want %g ± %g", what, len(a), got, ref, tol)
+	}
+}
+
+// inputKind fills vectors with one class of values.
+type inputKind struct {
+	name string
+	fill func(rng *rand.Rand, v []float32)
+}
+
+var inputKinds = []inputKind{
+	{"random", func(rng *rand.Rand, v []float32) {
+		for i := range v {
+			v[i] = rng.Float32()*2 - 1
+		}
+	}},
+	{"normalized", func(rng *rand.Rand, v []float32) {
+		var ss float64
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+			ss += float64(v[i]) * float64(v[i])
+		}
+		for i := range v {
+			v[i] = float32(float64(v[i]) / math.Sqrt(ss))
+		}
+	}},
+	{"denormal", func(rng *rand.Rand, v []float32) {
+		// Mix of denormals, tiny normals and ordinary values, so products land on both sides of
+		// the denormal boundary.
+		for i := range v {
+			switch rng.Intn(3) {
+			case 0:
+				v[i] = math.Float32frombits(uint32(rng.Intn(1<<23-1)+1)) * float32(1-2*rng.Intn(2))
+			case 1:
+				v[i] = (rng.Float32()*2 - 1) * 0x1p-120
+			default:
+				v[i] = rng.Float32()*2 - 1
+			}
+		}
+	}},
+	{"large", func(rng *rand.Rand, v []float32) {
+		// Products near 1e34; the sum of 1024 of them stays below MaxFloat32.
+		for i := range v {
+			v[i] = (rng.Float32()*2 - 1) * 1e17
+		}
+	}},
+	{"cancelling", func(rng *rand.Rand, v []float32) {
+		// Large terms of both signs around small ones: the result is far below Σ|aᵢbᵢ|.
+		for i := range v {
+			if i%2 == 0 {
+				v[i] = (rng.Float32()*2 - 1) * 1e6
+			} else {
+				v[i] = (rng.Float32()*2 - 1) * 1e-3
+			}
+		}
+	}},
+}
+
+// testLengths covers each kernel's unrolled body, its remainder loop and the scalar tail.
+var testLengths = []int{1, 2, 3, 4, 5, 7, 8, 9, 15, 16, 17, 31, 32, 33, 63, 64, 65, 100, 127, 511, Dim, 513, 1024}
+
+func TestDotProduct_KernelsMatchReference(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(1))
+		for _, in := range inputKinds {
+			for _, n := range testLengths {
+				a, b := make([]float32, n), make([]float32, n)
+				in.fill(rng, a)
+				in.fill(rng, b)
+				expectDot(t, in.name, a, b, DotProduct(a, b))
+				expectDot(t, in.name+" (kernel)", a, b, k.dot(a, b))
+			}
+		}
+	})
+}
+
+func TestDotProduct_KernelsSpecialValues(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(2))
+		for _, n := range testLengths {
+			for _, at := range []int{0, n / 2, n - 1} {
+				a, b := make([]float32, n), make([]float32, n)
+				for i := range a {
+					a[i] = rng.Float32() + 0.5
+					b[i] = rng.Float32() + 0.5
+				}
+				a[at] = float32(math.NaN())
+				if got := DotProduct(a, b); !math.IsNaN(got) {
+					t.Fatalf("len %d, NaN at %d: got %g, want NaN", n, at, got)
+				}
+				a[at] = float32(math.Inf(1))
+				if got := DotProduct(a, b); !math.IsInf(got, 1) {
+					t.Fatalf("len %d, +Inf at %d with positive terms: got %g, want +Inf", n, at, got)
+				}
+				b[at] = 0
+				if got := DotProduct(a, b); !math.IsNaN(got) {
+					t.Fatalf("len %d, Inf·0 at %d: got %g, want NaN", n, at, got)
+				}
+			}
+		}
+		// Empty and mismatched inputs are rejected before reaching the kernel.
+		if got := DotProduct(nil, nil); got != 0 {
+			t.Fatalf("DotProduct(nil, nil) = %g, want 0", got)
+		}
+		if got := DotProduct(make([]float32, 4), make([]float32, 5)); got != 0 {
+			t.Fatalf("DotProduct of mismatched lengths = %g, want 0", got)
+		}
+	})
+}
+
+func TestDotProductBatchFlat_KernelsMatchReference(t *testing.T) {
+	const sentinel = -12345.0
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(3))
+		for _, in := range inputKinds {
+			for _, n := range []int{1, 2, 3, 4, 5, 8, 17, 64} {
+				query := make([]float32, Dim)
+				data := make([]float32, n*Dim)
+				in.fill(rng, query)
+				in.fill(rng, data)
+				dst := make([]float64, n+1)
+				dst[n] = sentinel
+				got := DotProductBatchFlatInto(query, data, n, dst)
+				if len(got) != n || dst[n] != sentinel {
+					t.Fatalf("%s n=%d: got %d scores, dst[n]=%g", in.name, n, len(got), dst[n])
+				}
+				for i, s := range got {
+					expectDot(t, in.name, query, data[i*Dim:(i+1)*Dim], s)
+				}
+			}
+		}
+		// A NaN in one vector poisons that vector's score only.
+		query := make([]float32, Dim)
+		data := make([]float32, 6*Dim)
+		inputKinds[0].fill(rng, query)
+		inputKinds[0].fill(rng, data)
+		data[3*Dim+Dim/3] = float32(math.NaN())
+		for i, s := range DotProductBatchFlat(query, data, 6) {
+			if math.IsNaN(s) != (i == 3) {
+				t.Fatalf("NaN in vector 3: score %d = %g", i, s)
+			}
+		}
+	})
+}
+
+func TestDotProductBatchMulti_KernelsMatchReference(t *testing.T) {
+	const sentinel = -12345.0
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(4))
+		for _, in := range inputKinds {
+			for _, nq := range []int{1, 2, 3, 4, 5, 7, 9} {
+				for _, n := range []int{1, 3, 16} {
+					queries := make([][]float32, nq)
+					for q := range queries {
+						queries[q] = make([]float32, Dim)
+						in.fill(rng, queries[q])
+					}
+					data := make([]float32, n*Dim)
+					in.fill(rng, data)
+					stride := n + 2 // gaps between rows must be left alone
+					out := make([]float64, nq*stride)
+					for i := range out {
+						out[i] = sentinel
+					}
+					if DotProductBatchMultiStrided(queries, data, n, out, stride) == nil {
+						t.Fatalf("%s nq=%d n=%d: rejected", in.name, nq, n)
+					}
+					for q := range queries {
+						for i := 0; i < stride; i++ {
+							s := out[q*stride+i]
+							if i >= n {
+								if s != sentinel {
+									t.Fatalf("%s nq=%d n=%d: gap out[%d][%d] overwritten with %g", in.name, nq, n, q, i, s)
+								}
+								continue
+							}
+							expectDot(t, in.name, queries[q], data[i*Dim:(i+1)*Dim], s)
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestCapabilities_ReportsKernels(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		c := Capabilities()
+		if c.Kernel != k.name || c.Dot != k.desc || c.Batch != k.batchDesc || c.Batch != DotProductBatchDesc() {
+			t.Fatalf("kernel %s: capabilities %+v", k.name, c)
+		}
+	})
+}
+
+// SetImplementation may run while other goroutines score vectors; run with -race.
+func TestSetImplementation_Concurrent(t *testing.T) {
+	defer active.Store(active.Load())
+	rng := rand.New(rand.NewSource(5))
+	query := make([]float32, Dim)
+	data := make([]float32, 4*Dim)
+	inputKinds[0].fill(rng, query)
+	inputKinds[0].fill(rng, data)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ks := supportedKernels()
+		for i := 0; i < 200; i++ {
+			if err := SetImplementation(ks[i%len(ks)].name); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	queries := [][]float32{query, query}
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		expectDot(t, "concurrent", query, data[:Dim], DotProduct(query, data[:Dim]))
+		for i, s := range DotProductBatchFlat(query, data, 4) {
+			expectDot(t, "concurrent batch", query, data[i*Dim:(i+1)*Dim], s)
+		}
+		DotProductBatchMulti(queries, data, 4, make([]float64, 8))
+		_ = Capabilities()
+	}
+}
+
+// float32sFromBytes reinterprets b as little-endian float32 bit patterns, so the fuzzer can reach
+// every NaN payload, infinity and denormal directly.
+func float32sFromBytes(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return v
+}
+
+func bytesFromFloat32s(v ...float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(x))
+	}
+	return b
+}
+
+func addDotSeeds(f *testing.F) {
+	f.Add(bytesFromFloat32s(1, 2, 3, 4, 5, 6, 7, 8))
+	f.Add(bytesFromFloat32s(0.5, -0.25, 1e-3, 3, 7, -1, 2, 9, 4, 1))
+	f.Add(bytesFromFloat32s(float32(math.NaN()), 1, 2, 3))
+	f.Add(bytesFromFloat32s(float32(math.Inf(1)), 0, 1, float32(math.Inf(-1))))
+	f.Add(bytesFromFloat32s(math.SmallestNonzeroFloat32, 1e-40, 1e-38, 3, 1e-45, 1e-39))
+	f.Add(bytesFromFloat32s(1e17, -1e17, 3e19, 1e19, 2e18, -5e18))
+	f.Add(bytesFromFloat32s(math.MaxFloat32, math.MaxFloat32, 2, 2))
+}
+
+// FuzzDotProduct splits the input into two vectors and checks DotProduct under every supported
+// kernel against the reference.
+func FuzzDotProduct(f *testing.F) {
+	addDotSeeds(f)
+	ks := supportedKernels()
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		v := float32sFromBytes(raw)
+		n := len(v) / 2
+		if n == 0 {
+			return
+		}
+		a, b := v[:n], v[n:2*n]
+		defer active.Store(active.Load())
+		for _, k := range ks {
+			useKernel(k, true)
+			expectDot(t, k.name, a, b, DotProduct(a, b))
+		}
+	})
+}
+
+// FuzzDotProductBatchFlat tiles the input values over a query and n vectors and checks
+// DotProductBatchFlat under every supported kernel against the reference.
+func FuzzDotProductBatchFlat(f *testing.F) {
+	addDotSeeds(f)
+	ks := supportedKernels()
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		v := float32sFromBytes(raw)
+		if len(v) == 0 {
+			return
+		}
+		n := 1 + len(raw)%9
+		buf := make([]float32, (n+1)*Dim)
+		for i := range buf {
+			buf[i] = v[i%len(v)]
+		}
+		query, data := buf[:Dim], buf[Dim:]
+		defer active.Store(active.Load())
+		for _, k := range ks {
+			useKernel(k, true)
+			scores := DotProductBatchFlat(query, data, n)
+			if len(scores) != n {
+				t.Fatalf("%s: got %d scores, want %d", k.name, len(scores), n)
+			}
+			for i, s := range scores {
+				expectDot(t, k.name, query, data[i*Dim:(i+1)*Dim], s)
+			}
+		}
+	})
+}
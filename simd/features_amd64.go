@@ -0,0 +1,26 @@
+package simd
+
+import "golang.org/x/sys/cpu"
+
+// cpuFeatures lists the detected CPU features the amd64 kernels depend on.
+func cpuFeatures() []string {
+	var f []string
+	for _, c := range []struct {
+		name string
+		has  bool
+	}{
+		{"sse4.1", cpu.X86.HasSSE41},
+		{"avx", cpu.X86.HasAVX},
+		{"avx2", cpu.X86.HasAVX2},
+		{"fma", cpu.X86.HasFMA},
+		{"avx512f", cpu.X86.HasAVX512F},
+		{"avx512dq", cpu.X86.HasAVX512DQ},
+		{"avx512bw", cpu.X86.HasAVX512BW},
+		{"avx512vl", cpu.X86.HasAVX512VL},
+	} {
+		if c.has {
+			f = append(f, c.name)
+		}
+	}
+	return f
+}
@@ -0,0 +1,15 @@
+package simd
+
+import "golang.org/x/sys/cpu"
+
+// cpuFeatures lists the detected CPU features the arm64 kernels depend on.
+func cpuFeatures() []string {
+	var f []string
+	if cpu.ARM64.HasFP {
+		f = append(f, "fp")
+	}
+	if cpu.ARM64.HasASIMD {
+		f = append(f, "asimd")
+	}
+	return f
+}
@@ -0,0 +1,157 @@
+package simd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// EnvKernel is the environment variable that pins the kernel at startup, e.g. DAHVRI_SIMD=avx2.
+// It takes the names accepted by SetImplementation. An unknown or unsupported value is ignored
+// (the best kernel is used) and reported in Capabilities().EnvError.
+const EnvKernel = "DAHVRI_SIMD"
+
+// kernel is one implementation family of the dot product kernels. multi4 scores four queries
+// against n vectors, writing vector i's score for query j to out[j][i]; queries may repeat, in
+// which case their rows must be the same, and nil uses dotProductBatchMultiGo.
+type kernel struct {
+	name      string // SetImplementation name: "go", "sse4", "avx2", "avx512", "neon"
+	desc      string // DotProductDesc text, e.g. "AVX-512 (asm)"
+	batchDesc string // DotProductBatchDesc text, e.g. "AVX-512 + prefetch (asm)"
+	supported bool   // the CPU has the instructions the kernel uses
+	dot       func(a, b []float32) float64
+	batch     func(query, data []float32, n int, dst []float64)
+	multi4    func(queries [multiTile]*float32, data *float32, n int, out [multiTile]*float64) // nil: Go
+	l2sq      func(a, b []float32) float64
+	add       func(dst, src []float32)
+	scale     func(dst, src []float32, s float32)
+}
+
+var goKernel = kernel{
+	name: "go", desc: "Go", batchDesc: "Go", supported: true,
+	dot: dotProductGo, batch: dotProductBatchFlatGo,
+	l2sq: l2SquaredGo, add: addGo, scale: scaleGo,
+}
+
+// kernels lists the kernels built for this GOARCH and CGO setting, best first; goKernel is last.
+var kernels = append(archKernels(), goKernel)
+
+// kernelSet is the kernel family in use, published whole through active: SetImplementation may
+// run during Search, and each call then uses either the old family or the new one, never a mix.
+type kernelSet struct {
+	kernel
+	pinned bool // chosen with DAHVRI_SIMD or SetImplementation
+}
+
+var (
+	active       atomic.Pointer[kernelSet] // set by init before any kernel runs
+	envKernelErr string
+)
+
+func init() {
+	if v := os.Getenv(EnvKernel); v != "" {
+		if err := SetImplementation(v); err != nil {
+			envKernelErr = err.Error()
+			useKernel(bestKernel(), false)
+		}
+		return
+	}
+	useKernel(bestKernel(), false)
+}
+
+func bestKernel() kernel {
+	for _, k := range kernels {
+		if k.supported {
+			return k
+		}
+	}
+	return goKernel
+}
+
+func useKernel(k kernel, pinned bool) {
+	active.Store(&kernelSet{kernel: k, pinned: pinned})
+}
+
+// SetImplementation pins the kernels (DotProduct, DotProductBatchFlat, DotProductBatchMulti and
+// the vector helpers L2Squared, AddInto, ScaleInto and those built on them) to one family: "go", "sse4", "avx2", "avx512" or "neon", or "auto" for the
+// best one the CPU supports. It refuses, leaving the current kernels in place, a name that is
+// unknown, not built into this binary (SSE4 needs CGO, NEON needs arm64) or not supported by the
+// CPU. Each kernel is compiled for its own instruction set only (the C kernels through per-function
+// target attributes, not package-wide CFLAGS), so a forced choice cannot end in SIGILL. It is safe
+// to call concurrently with the kernels.
+func SetImplementation(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "-", "")
+	if name == "auto" {
+		useKernel(bestKernel(), false)
+		return nil
+	}
+	for _, k := range kernels {
+		if k.name != name {
+			continue
+		}
+		if !k.supported {
+			return fmt.Errorf("simd: kernel %q is not supported by this CPU", name)
+		}
+		useKernel(k, true)
+		return nil
+	}
+	switch name {
+	case "go", "sse4", "avx2", "avx512", "neon":
+		return fmt.Errorf("simd: kernel %q is not built into this binary (%s, cgo=%v; built: %s)",
+			name, runtime.GOARCH, cgoBuild, strings.Join(kernelNames(false), ", "))
+	}
+	return fmt.Errorf("simd: unknown kernel %q", name)
+}
+
+func kernelNames(supportedOnly bool) []string {
+	var names []string
+	for _, k := range kernels {
+		if k.supported || !supportedOnly {
+			names = append(names, k.name)
+		}
+	}
+	return names
+}
+
+// KernelInfo describes the CPU features relevant to the kernels and the kernels in use.
+type KernelInfo struct {
+	GOARCH    string
+	CGO       bool     // C kernels (true) or Go assembly kernels
+	Features  []string // detected CPU features, e.g. "avx2", "fma", "avx512f", "asimd"
+	Available []string // kernels usable on this CPU, best first
+	Kernel    string   // active kernel family
+	Pinned    bool     // chosen with DAHVRI_SIMD or SetImplementation rather than automatically
+	Dot       string   // DotProduct kernel
+	Batch     string   // DotProductBatchFlat kernel
+	Multi     string   // DotProductBatchMulti kernel
+	EnvError  string   // why DAHVRI_SIMD was ignored, if it was
+}
+
+// Capabilities reports the CPU features and the selected kernels (for logging and diagnostics).
+func Capabilities() KernelInfo {
+	ks := active.Load()
+	multi := ks.desc
+	if ks.multi4 == nil {
+		multi = goKernel.desc
+	}
+	return KernelInfo{
+		GOARCH:    runtime.GOARCH,
+		CGO:       cgoBuild,
+		Features:  cpuFeatures(),
+		Available: kernelNames(true),
+		Kernel:    ks.name,
+		Pinned:    ks.pinned,
+		Dot:       ks.desc,
+		Batch:     ks.batchDesc,
+		Multi:     multi,
+		EnvError:  envKernelErr,
+	}
+}
+
+// DotProductBatchDesc returns a description of the current DotProductBatchFlat implementation (for logging).
+func DotProductBatchDesc() string {
+	return active.Load().batchDesc
+}
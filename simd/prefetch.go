@@ -0,0 +1,16 @@
+package simd
+
+import "unsafe"
+
+// CacheLineSize is the prefetch granularity in bytes.
+const CacheLineSize = 64
+
+// Prefetch asks the CPU to start loading data into cache ahead of use, one hint per cache line
+// (PREFETCHT0 on amd64, PRFM PLDL1KEEP on arm64; assembly, or the C intrinsics in CGO builds).
+// Hints never fault and do not change results. On other architectures it does nothing.
+func Prefetch(data []float32) {
+	if len(data) == 0 {
+		return
+	}
+	prefetchLines(unsafe.SliceData(data), len(data)*4)
+}
@@ -0,0 +1,15 @@
+//go:build amd64 && !cgo
+
+#include "textflag.h"
+
+// func prefetchLines(p *float32, n int)
+TEXT ·prefetchLines(SB), NOSPLIT, $0-16
+	MOVQ p+0(FP), SI
+	MOVQ n+8(FP), CX
+
+prefetch_loop:
+	PREFETCHT0 (SI)
+	ADDQ $64, SI
+	SUBQ $64, CX
+	JG   prefetch_loop
+	RET
@@ -0,0 +1,15 @@
+//go:build arm64 && !cgo
+
+#include "textflag.h"
+
+// func prefetchLines(p *float32, n int)
+TEXT ·prefetchLines(SB), NOSPLIT, $0-16
+	MOVD p+0(FP), R0
+	MOVD n+8(FP), R1
+
+prefetch_loop:
+	PRFM (R0), PLDL1KEEP
+	ADD  $64, R0
+	SUBS $64, R1
+	BGT  prefetch_loop
+	RET
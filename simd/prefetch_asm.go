@@ -0,0 +1,10 @@
+//go:build (amd64 || arm64) && !cgo
+
+package simd
+
+// Implemented in prefetch_amd64.s and prefetch_arm64.s; the CGO build uses prefetch_cgo.go.
+
+// prefetchLines issues one prefetch per cache line of the n bytes at p.
+//
+//go:noescape
+func prefetchLines(p *float32, n int)
@@ -0,0 +1,27 @@
+//go:build (amd64 || arm64) && cgo
+
+package simd
+
+/*
+#include <stddef.h>
+#if defined(__x86_64__)
+#include <xmmintrin.h>
+#endif
+
+void PrefetchLines(const char* p, ptrdiff_t n) {
+	for (ptrdiff_t off = 0; off < n; off += 64) {
+#if defined(__x86_64__)
+		_mm_prefetch(p + off, _MM_HINT_T0);
+#else
+		__builtin_prefetch(p + off, 0, 3); // PRFM PLDL1KEEP
+#endif
+	}
+}
+*/
+import "C"
+
+import "unsafe"
+
+func prefetchLines(p *float32, n int) {
+	C.PrefetchLines((*C.char)(unsafe.Pointer(p)), C.ptrdiff_t(n))
+}
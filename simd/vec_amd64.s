@@ -0,0 +1,340 @@
+//go:build amd64 && !cgo
+
+#include "textflag.h"
+
+// Go assembly counterparts of the CGO kernels in vec_avx2.go and vec_avx512.go.
+// L2 sums are accumulated in float32 like the C versions and widened to float64 on return.
+
+// func l2SquaredAVX2(a, b []float32) float64
+TEXT ·l2SquaredAVX2(SB), NOSPLIT, $0-56
+	MOVQ a_base+0(FP), SI
+	MOVQ b_base+24(FP), DI
+	MOVQ a_len+8(FP), CX
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+
+l2avx2_loop32:
+	CMPQ CX, $32
+	JL   l2avx2_loop8
+	VMOVUPS (SI), Y4
+	VMOVUPS 32(SI), Y5
+	VMOVUPS 64(SI), Y6
+	VMOVUPS 96(SI), Y7
+	VSUBPS  (DI), Y4, Y4
+	VSUBPS  32(DI), Y5, Y5
+	VSUBPS  64(DI), Y6, Y6
+	VSUBPS  96(DI), Y7, Y7
+	VFMADD231PS Y4, Y4, Y0
+	VFMADD231PS Y5, Y5, Y1
+	VFMADD231PS Y6, Y6, Y2
+	VFMADD231PS Y7, Y7, Y3
+	ADDQ $128, SI
+	ADDQ $128, DI
+	SUBQ $32, CX
+	JMP  l2avx2_loop32
+
+l2avx2_loop8:
+	CMPQ CX, $8
+	JL   l2avx2_reduce
+	VMOVUPS (SI), Y4
+	VSUBPS  (DI), Y4, Y4
+	VFMADD231PS Y4, Y4, Y0
+	ADDQ $32, SI
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  l2avx2_loop8
+
+l2avx2_reduce:
+	VADDPS       Y1, Y0, Y0
+	VADDPS       Y3, Y2, Y2
+	VADDPS       Y2, Y0, Y0
+	VEXTRACTF128 $1, Y0, X1
+	VADDPS       X1, X0, X0
+	VHADDPS      X0, X0, X0
+	VHADDPS      X0, X0, X0
+	VZEROUPPER
+
+l2avx2_tail:
+	TESTQ CX, CX
+	JE    l2avx2_done
+	VMOVSS (SI), X1
+	VSUBSS (DI), X1, X1
+	VFMADD231SS X1, X1, X0
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  l2avx2_tail
+
+l2avx2_done:
+	VCVTSS2SD X0, X0, X0
+	MOVSD     X0, ret+48(FP)
+	RET
+
+// func l2SquaredAVX512(a, b []float32) float64
+TEXT ·l2SquaredAVX512(SB), NOSPLIT, $0-56
+	MOVQ a_base+0(FP), SI
+	MOVQ b_base+24(FP), DI
+	MOVQ a_len+8(FP), CX
+	VPXORD Z0, Z0, Z0
+	VPXORD Z1, Z1, Z1
+	VPXORD Z2, Z2, Z2
+	VPXORD Z3, Z3, Z3
+
+l2avx512_loop64:
+	CMPQ CX, $64
+	JL   l2avx512_loop16
+	VMOVUPS (SI), Z4
+	VMOVUPS 64(SI), Z5
+	VMOVUPS 128(SI), Z6
+	VMOVUPS 192(SI), Z7
+	VSUBPS  (DI), Z4, Z4
+	VSUBPS  64(DI), Z5, Z5
+	VSUBPS  128(DI), Z6, Z6
+	VSUBPS  192(DI), Z7, Z7
+	VFMADD231PS Z4, Z4, Z0
+	VFMADD231PS Z5, Z5, Z1
+	VFMADD231PS Z6, Z6, Z2
+	VFMADD231PS Z7, Z7, Z3
+	ADDQ $256, SI
+	ADDQ $256, DI
+	SUBQ $64, CX
+	JMP  l2avx512_loop64
+
+l2avx512_loop16:
+	CMPQ CX, $16
+	JL   l2avx512_reduce
+	VMOVUPS (SI), Z4
+	VSUBPS  (DI), Z4, Z4
+	VFMADD231PS Z4, Z4, Z0
+	ADDQ $64, SI
+	ADDQ $64, DI
+	SUBQ $16, CX
+	JMP  l2avx512_loop16
+
+l2avx512_reduce:
+	VADDPS        Z1, Z0, Z0
+	VADDPS        Z3, Z2, Z2
+	VADDPS        Z2, Z0, Z0
+	VEXTRACTF64X4 $1, Z0, Y1
+	VADDPS        Y1, Y0, Y0
+	VEXTRACTF128  $1, Y0, X1
+	VADDPS        X1, X0, X0
+	VHADDPS       X0, X0, X0
+	VHADDPS       X0, X0, X0
+	VZEROUPPER
+
+l2avx512_tail:
+	TESTQ CX, CX
+	JE    l2avx512_done
+	VMOVSS (SI), X1
+	VSUBSS (DI), X1, X1
+	VFMADD231SS X1, X1, X0
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  l2avx512_tail
+
+l2avx512_done:
+	VCVTSS2SD X0, X0, X0
+	MOVSD     X0, ret+48(FP)
+	RET
+
+// func addAVX2(dst, src []float32)
+TEXT ·addAVX2(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+addavx2_loop32:
+	CMPQ CX, $32
+	JL   addavx2_loop8
+	VMOVUPS (DI), Y0
+	VMOVUPS 32(DI), Y1
+	VMOVUPS 64(DI), Y2
+	VMOVUPS 96(DI), Y3
+	VADDPS  (SI), Y0, Y0
+	VADDPS  32(SI), Y1, Y1
+	VADDPS  64(SI), Y2, Y2
+	VADDPS  96(SI), Y3, Y3
+	VMOVUPS Y0, (DI)
+	VMOVUPS Y1, 32(DI)
+	VMOVUPS Y2, 64(DI)
+	VMOVUPS Y3, 96(DI)
+	ADDQ $128, SI
+	ADDQ $128, DI
+	SUBQ $32, CX
+	JMP  addavx2_loop32
+
+addavx2_loop8:
+	CMPQ CX, $8
+	JL   addavx2_tail
+	VMOVUPS (DI), Y0
+	VADDPS  (SI), Y0, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, SI
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  addavx2_loop8
+
+addavx2_tail:
+	TESTQ CX, CX
+	JE    addavx2_done
+	VMOVSS (DI), X0
+	VADDSS (SI), X0, X0
+	VMOVSS X0, (DI)
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  addavx2_tail
+
+addavx2_done:
+	VZEROUPPER
+	RET
+
+// func addAVX512(dst, src []float32)
+TEXT ·addAVX512(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+addavx512_loop64:
+	CMPQ CX, $64
+	JL   addavx512_loop16
+	VMOVUPS (DI), Z0
+	VMOVUPS 64(DI), Z1
+	VMOVUPS 128(DI), Z2
+	VMOVUPS 192(DI), Z3
+	VADDPS  (SI), Z0, Z0
+	VADDPS  64(SI), Z1, Z1
+	VADDPS  128(SI), Z2, Z2
+	VADDPS  192(SI), Z3, Z3
+	VMOVUPS Z0, (DI)
+	VMOVUPS Z1, 64(DI)
+	VMOVUPS Z2, 128(DI)
+	VMOVUPS Z3, 192(DI)
+	ADDQ $256, SI
+	ADDQ $256, DI
+	SUBQ $64, CX
+	JMP  addavx512_loop64
+
+addavx512_loop16:
+	CMPQ CX, $16
+	JL   addavx512_tail
+	VMOVUPS (DI), Z0
+	VADDPS  (SI), Z0, Z0
+	VMOVUPS Z0, (DI)
+	ADDQ $64, SI
+	ADDQ $64, DI
+	SUBQ $16, CX
+	JMP  addavx512_loop16
+
+addavx512_tail:
+	TESTQ CX, CX
+	JE    addavx512_done
+	VMOVSS (DI), X0
+	VADDSS (SI), X0, X0
+	VMOVSS X0, (DI)
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  addavx512_tail
+
+addavx512_done:
+	VZEROUPPER
+	RET
+
+// func scaleAVX2(dst, src []float32, s float32)
+TEXT ·scaleAVX2(SB), NOSPLIT, $0-52
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+	VBROADCASTSS s+48(FP), Y8
+
+scaleavx2_loop32:
+	CMPQ CX, $32
+	JL   scaleavx2_loop8
+	VMULPS  (SI), Y8, Y0
+	VMULPS  32(SI), Y8, Y1
+	VMULPS  64(SI), Y8, Y2
+	VMULPS  96(SI), Y8, Y3
+	VMOVUPS Y0, (DI)
+	VMOVUPS Y1, 32(DI)
+	VMOVUPS Y2, 64(DI)
+	VMOVUPS Y3, 96(DI)
+	ADDQ $128, SI
+	ADDQ $128, DI
+	SUBQ $32, CX
+	JMP  scaleavx2_loop32
+
+scaleavx2_loop8:
+	CMPQ CX, $8
+	JL   scaleavx2_tail
+	VMULPS  (SI), Y8, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, SI
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  scaleavx2_loop8
+
+scaleavx2_tail:
+	TESTQ CX, CX
+	JE    scaleavx2_done
+	VMULSS (SI), X8, X0
+	VMOVSS X0, (DI)
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  scaleavx2_tail
+
+scaleavx2_done:
+	VZEROUPPER
+	RET
+
+// func scaleAVX512(dst, src []float32, s float32)
+TEXT ·scaleAVX512(SB), NOSPLIT, $0-52
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+	VBROADCASTSS s+48(FP), Z8
+
+scaleavx512_loop64:
+	CMPQ CX, $64
+	JL   scaleavx512_loop16
+	VMULPS  (SI), Z8, Z0
+	VMULPS  64(SI), Z8, Z1
+	VMULPS  128(SI), Z8, Z2
+	VMULPS  192(SI), Z8, Z3
+	VMOVUPS Z0, (DI)
+	VMOVUPS Z1, 64(DI)
+	VMOVUPS Z2, 128(DI)
+	VMOVUPS Z3, 192(DI)
+	ADDQ $256, SI
+	ADDQ $256, DI
+	SUBQ $64, CX
+	JMP  scaleavx512_loop64
+
+scaleavx512_loop16:
+	CMPQ CX, $16
+	JL   scaleavx512_tail
+	VMULPS  (SI), Z8, Z0
+	VMOVUPS Z0, (DI)
+	ADDQ $64, SI
+	ADDQ $64, DI
+	SUBQ $16, CX
+	JMP  scaleavx512_loop16
+
+scaleavx512_tail:
+	TESTQ CX, CX
+	JE    scaleavx512_done
+	VMULSS (SI), X8, X0
+	VMOVSS X0, (DI)
+	ADDQ $4, SI
+	ADDQ $4, DI
+	DECQ CX
+	JMP  scaleavx512_tail
+
+scaleavx512_done:
+	VZEROUPPER
+	RET
@@ -0,0 +1,148 @@
+//go:build arm64 && !cgo
+
+#include "textflag.h"
+
+// Go assembly counterparts of the CGO kernels in vec_neon.go. L2 sums are accumulated in
+// float32 like the C version and widened to float64 on return. Vector FADD/FSUB/FMUL are
+// emitted as WORDs for assemblers without the mnemonics (see dot_arm64.s).
+
+#define REDUCE_V0 \
+	WORD $0x4e21d400 \ // FADD  V0.4S, V0.4S, V1.4S
+	WORD $0x4e23d442 \ // FADD  V2.4S, V2.4S, V3.4S
+	WORD $0x4e22d400 \ // FADD  V0.4S, V0.4S, V2.4S
+	WORD $0x6e20d400 \ // FADDP V0.4S, V0.4S, V0.4S
+	WORD $0x7e30d800   // FADDP S0, V0.2S
+
+// func l2SquaredNEON(a, b []float32) float64
+TEXT ·l2SquaredNEON(SB), NOSPLIT, $0-56
+	MOVD a_base+0(FP), R0
+	MOVD a_len+8(FP), R2
+	MOVD b_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+l2neon_loop16:
+	CMP  $16, R2
+	BLT  l2neon_loop4
+	VLD1.P 64(R0), [V4.S4, V5.S4, V6.S4, V7.S4]
+	VLD1.P 64(R1), [V16.S4, V17.S4, V18.S4, V19.S4]
+	WORD $0x4eb0d484 // FSUB V4.4S, V4.4S, V16.4S
+	WORD $0x4eb1d4a5 // FSUB V5.4S, V5.4S, V17.4S
+	WORD $0x4eb2d4c6 // FSUB V6.4S, V6.4S, V18.4S
+	WORD $0x4eb3d4e7 // FSUB V7.4S, V7.4S, V19.4S
+	VFMLA V4.S4, V4.S4, V0.S4
+	VFMLA V5.S4, V5.S4, V1.S4
+	VFMLA V6.S4, V6.S4, V2.S4
+	VFMLA V7.S4, V7.S4, V3.S4
+	SUB  $16, R2
+	B    l2neon_loop16
+
+l2neon_loop4:
+	CMP  $4, R2
+	BLT  l2neon_reduce
+	VLD1.P 16(R0), [V4.S4]
+	VLD1.P 16(R1), [V16.S4]
+	WORD $0x4eb0d484 // FSUB V4.4S, V4.4S, V16.4S
+	VFMLA V4.S4, V4.S4, V0.S4
+	SUB  $4, R2
+	B    l2neon_loop4
+
+l2neon_reduce:
+	REDUCE_V0
+
+l2neon_tail:
+	CBZ  R2, l2neon_done
+	FMOVS.P 4(R0), F4
+	FMOVS.P 4(R1), F5
+	FSUBS  F5, F4, F4
+	FMADDS F4, F0, F4, F0
+	SUB  $1, R2
+	B    l2neon_tail
+
+l2neon_done:
+	FCVTSD F0, F0
+	FMOVD  F0, ret+48(FP)
+	RET
+
+// func addNEON(dst, src []float32)
+TEXT ·addNEON(SB), NOSPLIT, $0-48
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD src_base+24(FP), R1
+
+addneon_loop16:
+	CMP  $16, R2
+	BLT  addneon_loop4
+	VLD1   (R0), [V0.S4, V1.S4, V2.S4, V3.S4]
+	VLD1.P 64(R1), [V4.S4, V5.S4, V6.S4, V7.S4]
+	WORD $0x4e24d400 // FADD V0.4S, V0.4S, V4.4S
+	WORD $0x4e25d421 // FADD V1.4S, V1.4S, V5.4S
+	WORD $0x4e26d442 // FADD V2.4S, V2.4S, V6.4S
+	WORD $0x4e27d463 // FADD V3.4S, V3.4S, V7.4S
+	VST1.P [V0.S4, V1.S4, V2.S4, V3.S4], 64(R0)
+	SUB  $16, R2
+	B    addneon_loop16
+
+addneon_loop4:
+	CMP  $4, R2
+	BLT  addneon_tail
+	VLD1   (R0), [V0.S4]
+	VLD1.P 16(R1), [V4.S4]
+	WORD $0x4e24d400 // FADD V0.4S, V0.4S, V4.4S
+	VST1.P [V0.S4], 16(R0)
+	SUB  $4, R2
+	B    addneon_loop4
+
+addneon_tail:
+	CBZ  R2, addneon_done
+	FMOVS   (R0), F0
+	FMOVS.P 4(R1), F1
+	FADDS   F1, F0, F0
+	FMOVS.P F0, 4(R0)
+	SUB  $1, R2
+	B    addneon_tail
+
+addneon_done:
+	RET
+
+// func scaleNEON(dst, src []float32, s float32)
+TEXT ·scaleNEON(SB), NOSPLIT, $0-52
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD src_base+24(FP), R1
+	MOVD $s+48(FP), R3
+	VLD1R (R3), [V8.S4]
+
+scaleneon_loop16:
+	CMP  $16, R2
+	BLT  scaleneon_loop4
+	VLD1.P 64(R1), [V0.S4, V1.S4, V2.S4, V3.S4]
+	WORD $0x6e28dc00 // FMUL V0.4S, V0.4S, V8.4S
+	WORD $0x6e28dc21 // FMUL V1.4S, V1.4S, V8.4S
+	WORD $0x6e28dc42 // FMUL V2.4S, V2.4S, V8.4S
+	WORD $0x6e28dc63 // FMUL V3.4S, V3.4S, V8.4S
+	VST1.P [V0.S4, V1.S4, V2.S4, V3.S4], 64(R0)
+	SUB  $16, R2
+	B    scaleneon_loop16
+
+scaleneon_loop4:
+	CMP  $4, R2
+	BLT  scaleneon_tail
+	VLD1.P 16(R1), [V0.S4]
+	WORD $0x6e28dc00 // FMUL V0.4S, V0.4S, V8.4S
+	VST1.P [V0.S4], 16(R0)
+	SUB  $4, R2
+	B    scaleneon_loop4
+
+scaleneon_tail:
+	CBZ  R2, scaleneon_done
+	FMOVS.P 4(R1), F0
+	FMULS   F8, F0, F0
+	FMOVS.P F0, 4(R0)
+	SUB  $1, R2
+	B    scaleneon_tail
+
+scaleneon_done:
+	RET
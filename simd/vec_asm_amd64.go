@@ -0,0 +1,23 @@
+//go:build amd64 && !cgo
+
+package simd
+
+// Implemented in vec_amd64.s; the CGO build uses the C kernels of the same names instead.
+
+//go:noescape
+func l2SquaredAVX2(a, b []float32) float64
+
+//go:noescape
+func l2SquaredAVX512(a, b []float32) float64
+
+//go:noescape
+func addAVX2(dst, src []float32)
+
+//go:noescape
+func addAVX512(dst, src []float32)
+
+//go:noescape
+func scaleAVX2(dst, src []float32, s float32)
+
+//go:noescape
+func scaleAVX512(dst, src []float32, s float32)
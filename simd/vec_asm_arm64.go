@@ -0,0 +1,14 @@
+//go:build arm64 && !cgo
+
+package simd
+
+// Implemented in vec_arm64.s; the CGO build uses the C kernels of the same names instead.
+
+//go:noescape
+func l2SquaredNEON(a, b []float32) float64
+
+//go:noescape
+func addNEON(dst, src []float32)
+
+//go:noescape
+func scaleNEON(dst, src []float32, s float32)
@@ -0,0 +1,78 @@
+//go:build amd64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <immintrin.h>
+#include <stddef.h>
+
+__attribute__((target("avx2,fma")))
+static float L2SquaredAVX2(const float* a, const float* b, size_t n) {
+	__m256 sum = _mm256_setzero_ps();
+	size_t i = 0;
+	for (; i + 8 <= n; i += 8) {
+		__m256 d = _mm256_sub_ps(_mm256_loadu_ps(a + i), _mm256_loadu_ps(b + i));
+		sum = _mm256_add_ps(sum, _mm256_mul_ps(d, d));
+	}
+	__m128 s4 = _mm_add_ps(_mm256_extractf128_ps(sum, 1), _mm256_castps256_ps128(sum));
+	s4 = _mm_hadd_ps(s4, s4);
+	s4 = _mm_hadd_ps(s4, s4);
+	float s = _mm_cvtss_f32(s4);
+	for (; i < n; i++) {
+		float d = a[i] - b[i];
+		s += d * d;
+	}
+	return s;
+}
+
+__attribute__((target("avx2,fma")))
+static void AddAVX2(float* dst, const float* src, size_t n) {
+	size_t i = 0;
+	for (; i + 8 <= n; i += 8) {
+		_mm256_storeu_ps(dst + i, _mm256_add_ps(_mm256_loadu_ps(dst + i), _mm256_loadu_ps(src + i)));
+	}
+	for (; i < n; i++) dst[i] += src[i];
+}
+
+__attribute__((target("avx2,fma")))
+static void ScaleAVX2(float* dst, const float* src, size_t n, float s) {
+	__m256 vs = _mm256_set1_ps(s);
+	size_t i = 0;
+	for (; i + 8 <= n; i += 8) {
+		_mm256_storeu_ps(dst + i, _mm256_mul_ps(_mm256_loadu_ps(src + i), vs));
+	}
+	for (; i < n; i++) dst[i] = src[i] * s;
+}
+*/
+import "C"
+
+import "unsafe"
+
+func l2SquaredAVX2(a, b []float32) float64 {
+	n := len(a)
+	if n == 0 {
+		return 0
+	}
+	return float64(C.L2SquaredAVX2(
+		(*C.float)(unsafe.Pointer(&a[0])),
+		(*C.float)(unsafe.Pointer(&b[0])),
+		C.size_t(n),
+	))
+}
+
+func addAVX2(dst, src []float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.AddAVX2((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n))
+}
+
+func scaleAVX2(dst, src []float32, s float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.ScaleAVX2((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n), C.float(s))
+}
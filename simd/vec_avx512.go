@@ -0,0 +1,75 @@
+//go:build amd64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <immintrin.h>
+#include <stddef.h>
+
+__attribute__((target("avx512f")))
+static float L2SquaredAVX512(const float* a, const float* b, size_t n) {
+	__m512 sum = _mm512_setzero_ps();
+	size_t i = 0;
+	for (; i + 16 <= n; i += 16) {
+		__m512 d = _mm512_sub_ps(_mm512_loadu_ps(a + i), _mm512_loadu_ps(b + i));
+		sum = _mm512_fmadd_ps(d, d, sum);
+	}
+	float s = _mm512_reduce_add_ps(sum);
+	for (; i < n; i++) {
+		float d = a[i] - b[i];
+		s += d * d;
+	}
+	return s;
+}
+
+__attribute__((target("avx512f")))
+static void AddAVX512(float* dst, const float* src, size_t n) {
+	size_t i = 0;
+	for (; i + 16 <= n; i += 16) {
+		_mm512_storeu_ps(dst + i, _mm512_add_ps(_mm512_loadu_ps(dst + i), _mm512_loadu_ps(src + i)));
+	}
+	for (; i < n; i++) dst[i] += src[i];
+}
+
+__attribute__((target("avx512f")))
+static void ScaleAVX512(float* dst, const float* src, size_t n, float s) {
+	__m512 vs = _mm512_set1_ps(s);
+	size_t i = 0;
+	for (; i + 16 <= n; i += 16) {
+		_mm512_storeu_ps(dst + i, _mm512_mul_ps(_mm512_loadu_ps(src + i), vs));
+	}
+	for (; i < n; i++) dst[i] = src[i] * s;
+}
+*/
+import "C"
+
+import "unsafe"
+
+func l2SquaredAVX512(a, b []float32) float64 {
+	n := len(a)
+	if n == 0 {
+		return 0
+	}
+	return float64(C.L2SquaredAVX512(
+		(*C.float)(unsafe.Pointer(&a[0])),
+		(*C.float)(unsafe.Pointer(&b[0])),
+		C.size_t(n),
+	))
+}
+
+func addAVX512(dst, src []float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.AddAVX512((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n))
+}
+
+func scaleAVX512(dst, src []float32, s float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.ScaleAVX512((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n), C.float(s))
+}
@@ -0,0 +1,74 @@
+//go:build arm64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <arm_neon.h>
+#include <stddef.h>
+
+static float L2SquaredNEON(const float* a, const float* b, size_t n) {
+	float32x4_t sum0 = vdupq_n_f32(0.0f);
+	float32x4_t sum1 = vdupq_n_f32(0.0f);
+	size_t i = 0;
+	for (; i + 8 <= n; i += 8) {
+		float32x4_t d0 = vsubq_f32(vld1q_f32(a + i), vld1q_f32(b + i));
+		float32x4_t d1 = vsubq_f32(vld1q_f32(a + i + 4), vld1q_f32(b + i + 4));
+		sum0 = vmlaq_f32(sum0, d0, d0);
+		sum1 = vmlaq_f32(sum1, d1, d1);
+	}
+	float s = vaddvq_f32(vaddq_f32(sum0, sum1));
+	for (; i < n; i++) {
+		float d = a[i] - b[i];
+		s += d * d;
+	}
+	return s;
+}
+
+static void AddNEON(float* dst, const float* src, size_t n) {
+	size_t i = 0;
+	for (; i + 4 <= n; i += 4) {
+		vst1q_f32(dst + i, vaddq_f32(vld1q_f32(dst + i), vld1q_f32(src + i)));
+	}
+	for (; i < n; i++) dst[i] += src[i];
+}
+
+static void ScaleNEON(float* dst, const float* src, size_t n, float s) {
+	size_t i = 0;
+	for (; i + 4 <= n; i += 4) {
+		vst1q_f32(dst + i, vmulq_n_f32(vld1q_f32(src + i), s));
+	}
+	for (; i < n; i++) dst[i] = src[i] * s;
+}
+*/
+import "C"
+
+import "unsafe"
+
+func l2SquaredNEON(a, b []float32) float64 {
+	n := len(a)
+	if n == 0 {
+		return 0
+	}
+	return float64(C.L2SquaredNEON(
+		(*C.float)(unsafe.Pointer(&a[0])),
+		(*C.float)(unsafe.Pointer(&b[0])),
+		C.size_t(n),
+	))
+}
+
+func addNEON(dst, src []float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.AddNEON((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n))
+}
+
+func scaleNEON(dst, src []float32, s float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.ScaleNEON((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n), C.float(s))
+}
@@ -0,0 +1,77 @@
+//go:build amd64 && cgo
+
+package simd
+
+/*
+#cgo CFLAGS: -O3
+#include <smmintrin.h>
+#include <stddef.h>
+
+__attribute__((target("sse4.1")))
+static float L2SquaredSSE4(const float* a, const float* b, size_t n) {
+	__m128 sum = _mm_setzero_ps();
+	size_t i = 0;
+	for (; i + 4 <= n; i += 4) {
+		__m128 d = _mm_sub_ps(_mm_loadu_ps(a + i), _mm_loadu_ps(b + i));
+		sum = _mm_add_ps(sum, _mm_mul_ps(d, d));
+	}
+	sum = _mm_hadd_ps(sum, sum);
+	sum = _mm_hadd_ps(sum, sum);
+	float s = _mm_cvtss_f32(sum);
+	for (; i < n; i++) {
+		float d = a[i] - b[i];
+		s += d * d;
+	}
+	return s;
+}
+
+__attribute__((target("sse4.1")))
+static void AddSSE4(float* dst, const float* src, size_t n) {
+	size_t i = 0;
+	for (; i + 4 <= n; i += 4) {
+		_mm_storeu_ps(dst + i, _mm_add_ps(_mm_loadu_ps(dst + i), _mm_loadu_ps(src + i)));
+	}
+	for (; i < n; i++) dst[i] += src[i];
+}
+
+__attribute__((target("sse4.1")))
+static void ScaleSSE4(float* dst, const float* src, size_t n, float s) {
+	__m128 vs = _mm_set1_ps(s);
+	size_t i = 0;
+	for (; i + 4 <= n; i += 4) {
+		_mm_storeu_ps(dst + i, _mm_mul_ps(_mm_loadu_ps(src + i), vs));
+	}
+	for (; i < n; i++) dst[i] = src[i] * s;
+}
+*/
+import "C"
+
+import "unsafe"
+
+func l2SquaredSSE4(a, b []float32) float64 {
+	n := len(a)
+	if n == 0 {
+		return 0
+	}
+	return float64(C.L2SquaredSSE4(
+		(*C.float)(unsafe.Pointer(&a[0])),
+		(*C.float)(unsafe.Pointer(&b[0])),
+		C.size_t(n),
+	))
+}
+
+func addSSE4(dst, src []float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.AddSSE4((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n))
+}
+
+func scaleSSE4(dst, src []float32, s float32) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	C.ScaleSSE4((*C.float)(unsafe.Pointer(&dst[0])), (*C.float)(unsafe.Pointer(&src[0])), C.size_t(n), C.float(s))
+}
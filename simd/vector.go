@@ -0,0 +1,102 @@
+package simd
+
+import "math"
+
+// L2Squared returns the squared Euclidean distance between a and b, or 0 if their lengths differ
+// or are zero.
+func L2Squared(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	return active.Load().l2sq(a, b)
+}
+
+// Norm returns the L2 norm of v.
+func Norm(v []float32) float64 {
+	return math.Sqrt(DotProduct(v, v))
+}
+
+// Normalize scales v in place to unit L2 norm and returns its norm before scaling. A zero,
+// infinite or NaN norm leaves v unchanged.
+func Normalize(v []float32) float64 {
+	norm := Norm(v)
+	if norm == 0 || math.IsInf(norm, 0) || math.IsNaN(norm) {
+		return norm
+	}
+	active.Load().scale(v, v, float32(1/norm))
+	return norm
+}
+
+// AddInto adds src to dst element-wise (dst += src) and returns dst, or nil if the lengths differ
+// or are zero.
+func AddInto(dst, src []float32) []float32 {
+	if len(dst) != len(src) || len(dst) == 0 {
+		return nil
+	}
+	active.Load().add(dst, src)
+	return dst
+}
+
+// ScaleInto writes src·s into dst and returns dst, or nil if the lengths differ or are zero.
+// dst may be src itself but must not otherwise overlap it.
+func ScaleInto(dst, src []float32, s float32) []float32 {
+	if len(dst) != len(src) || len(dst) == 0 {
+		return nil
+	}
+	active.Load().scale(dst, src, s)
+	return dst
+}
+
+// MeanInto writes the element-wise mean of vecs into dst and returns dst, or nil (leaving dst
+// unchanged) if vecs is empty or a vector's length differs from dst's. The sum is accumulated in
+// dst, which must not overlap any of vecs.
+func MeanInto(dst []float32, vecs [][]float32) []float32 {
+	if len(vecs) == 0 || len(dst) == 0 {
+		return nil
+	}
+	for _, v := range vecs {
+		if len(v) != len(dst) {
+			return nil
+		}
+	}
+	ks := active.Load()
+	copy(dst, vecs[0])
+	for _, v := range vecs[1:] {
+		ks.add(dst, v)
+	}
+	if len(vecs) > 1 {
+		ks.scale(dst, dst, float32(1/float64(len(vecs))))
+	}
+	return dst
+}
+
+// l2SquaredGo sums groups of four squared differences in float32 and the groups in float64, like
+// dotProductGo.
+func l2SquaredGo(a, b []float32) float64 {
+	var sum float64
+	b = b[:len(a)]
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		d0, d1, d2, d3 := a[i]-b[i], a[i+1]-b[i+1], a[i+2]-b[i+2], a[i+3]-b[i+3]
+		sum += float64(d0*d0 + d1*d1 + d2*d2 + d3*d3)
+	}
+	for ; i < len(a); i++ {
+		d := a[i] - b[i]
+		sum += float64(d * d)
+	}
+	return sum
+}
+
+func addGo(dst, src []float32) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] += src[i]
+	}
+}
+
+func scaleGo(dst, src []float32, s float32) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] = src[i] * s
+	}
+}
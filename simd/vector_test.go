@@ -0,0 +1,144 @@
+package simd
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// refL2Squared is the squared distance in float64 with Σ(aᵢ-bᵢ)², the scale of its rounding error.
+func refL2Squared(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return sum
+}
+
+func TestL2Squared_KernelsMatchReference(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(5))
+		for _, in := range inputKinds {
+			for _, n := range testLengths {
+				a, b := make([]float32, n), make([]float32, n)
+				in.fill(rng, a)
+				in.fill(rng, b)
+				ref := refL2Squared(a, b)
+				if math.IsInf(ref, 0) || ref > math.MaxFloat32/2 {
+					continue
+				}
+				// Each difference is rounded once to float32 before squaring, hence the extra 3.
+				tol := float64(n+5)*0x1p-23*ref + float64(n+2)*0x1p-149
+				for _, got := range []float64{L2Squared(a, b), k.l2sq(a, b)} {
+					if math.Abs(got-ref) > tol {
+						t.Fatalf("%s (len %d): got %g, want %g ± %g", in.name, n, got, ref, tol)
+					}
+				}
+			}
+		}
+		a := []float32{1, 2, float32(math.NaN()), 4, 5}
+		if got := L2Squared(a, make([]float32, 5)); !math.IsNaN(got) {
+			t.Fatalf("L2Squared with NaN = %g, want NaN", got)
+		}
+		if got := L2Squared(a, a[:4]); got != 0 {
+			t.Fatalf("L2Squared of mismatched lengths = %g, want 0", got)
+		}
+	})
+}
+
+// AddInto and ScaleInto are exact per element (one rounding), so every kernel must agree with
+// the scalar result bit for bit.
+func TestAddScaleInto_KernelsExact(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(6))
+		for _, in := range inputKinds {
+			for _, n := range testLengths {
+				dst, src := make([]float32, n+1), make([]float32, n)
+				in.fill(rng, dst)
+				in.fill(rng, src)
+				want := make([]float32, n)
+				for i := range want {
+					want[i] = dst[i] + src[i]
+				}
+				guard := dst[n]
+				if AddInto(dst[:n], src) == nil {
+					t.Fatalf("AddInto rejected len %d", n)
+				}
+				for i := range want {
+					if math.Float32bits(dst[i]) != math.Float32bits(want[i]) {
+						t.Fatalf("%s AddInto (len %d): [%d] = %g, want %g", in.name, n, i, dst[i], want[i])
+					}
+				}
+				s := rng.Float32()*4 - 2
+				for i := range want {
+					want[i] = src[i] * s
+				}
+				if ScaleInto(dst[:n], src, s) == nil {
+					t.Fatalf("ScaleInto rejected len %d", n)
+				}
+				for i := range want {
+					if math.Float32bits(dst[i]) != math.Float32bits(want[i]) {
+						t.Fatalf("%s ScaleInto (len %d): [%d] = %g, want %g", in.name, n, i, dst[i], want[i])
+					}
+				}
+				// In place, as Normalize and MeanInto use it.
+				copy(dst, src)
+				ScaleInto(dst[:n], dst[:n], s)
+				for i := range want {
+					if math.Float32bits(dst[i]) != math.Float32bits(want[i]) {
+						t.Fatalf("%s ScaleInto in place (len %d): [%d] = %g, want %g", in.name, n, i, dst[i], want[i])
+					}
+				}
+				if dst[n] != guard {
+					t.Fatalf("len %d: element past dst overwritten", n)
+				}
+			}
+		}
+		if AddInto(make([]float32, 3), make([]float32, 4)) != nil || ScaleInto(nil, nil, 2) != nil {
+			t.Fatal("invalid lengths accepted")
+		}
+	})
+}
+
+func TestNormalizeMeanInto(t *testing.T) {
+	forEachKernel(t, func(t *testing.T, k kernel) {
+		rng := rand.New(rand.NewSource(7))
+		v := make([]float32, Dim)
+		inputKinds[0].fill(rng, v)
+		_, sq := refDot(v, v)
+		norm := Normalize(v)
+		if math.Abs(norm-math.Sqrt(sq)) > 1e-5*norm {
+			t.Fatalf("Normalize returned %g, want %g", norm, math.Sqrt(sq))
+		}
+		if n := Norm(v); math.Abs(n-1) > 1e-6 {
+			t.Fatalf("Norm after Normalize = %g, want 1", n)
+		}
+		zero := make([]float32, Dim)
+		if Normalize(zero) != 0 || L2Squared(zero, make([]float32, Dim)) != 0 {
+			t.Fatal("zero vector changed by Normalize")
+		}
+
+		vecs := make([][]float32, 13)
+		want := make([]float64, Dim)
+		for i := range vecs {
+			vecs[i] = make([]float32, Dim)
+			inputKinds[0].fill(rng, vecs[i])
+			for j, x := range vecs[i] {
+				want[j] += float64(x) / float64(len(vecs))
+			}
+		}
+		mean := make([]float32, Dim)
+		if MeanInto(mean, vecs) == nil {
+			t.Fatal("MeanInto rejected valid input")
+		}
+		for j := range want {
+			if math.Abs(float64(mean[j])-want[j]) > 1e-5 {
+				t.Fatalf("mean[%d] = %g, want %g", j, mean[j], want[j])
+			}
+		}
+		if MeanInto(mean, nil) != nil || MeanInto(mean, [][]float32{vecs[0][:10]}) != nil {
+			t.Fatal("MeanInto accepted invalid input")
+		}
+	})
+}